@@ -0,0 +1,211 @@
+package gpb
+
+import (
+	"math"
+
+	"github.com/pkg/errors"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// Builder encodes a message field by field without generated types, e.g.
+//
+//	pb, err := gpb.NewBuilder(nil).
+//		Int32(1, 7).
+//		StartMessage(4).String(1, "label").String(2, "type").End().
+//		Sint32(102, -32).
+//		Build()
+//
+// Fields are written in the order appended, which produces the same bytes as proto.Marshal when
+// they are appended in the order of field numbers. The length prefixes of nested messages are
+// written back when the messages end, so the content is copied only for the messages longer than
+// 127 bytes.
+//
+// The zero value is an empty builder ready to use.
+type Builder struct {
+	buf []byte
+	// open the messages and groups started but not ended yet
+	open []openField
+	err  error
+}
+
+// openField is a message or group started in the builder.
+type openField struct {
+	number   protowire.Number
+	wireType protowire.Type
+	// start the offset of the content, a byte is reserved before it for the length prefix of
+	// messages
+	start int
+}
+
+// NewBuilder creates a builder which appends the fields to dst.
+func NewBuilder(dst []byte) *Builder {
+	return &Builder{buf: dst}
+}
+
+func (b *Builder) Int32(number protowire.Number, v int32) *Builder {
+	return b.varint(number, uint64(int64(v)))
+}
+
+func (b *Builder) Int64(number protowire.Number, v int64) *Builder {
+	return b.varint(number, uint64(v))
+}
+
+func (b *Builder) Uint32(number protowire.Number, v uint32) *Builder {
+	return b.varint(number, uint64(v))
+}
+
+func (b *Builder) Uint64(number protowire.Number, v uint64) *Builder {
+	return b.varint(number, v)
+}
+
+func (b *Builder) Bool(number protowire.Number, v bool) *Builder {
+	return b.varint(number, protowire.EncodeBool(v))
+}
+
+func (b *Builder) Sint32(number protowire.Number, v int32) *Builder {
+	return b.varint(number, protowire.EncodeZigZag(int64(v)))
+}
+
+func (b *Builder) Sint64(number protowire.Number, v int64) *Builder {
+	return b.varint(number, protowire.EncodeZigZag(v))
+}
+
+func (b *Builder) Fixed32(number protowire.Number, v uint32) *Builder {
+	b.buf = protowire.AppendTag(b.buf, number, protowire.Fixed32Type)
+	b.buf = protowire.AppendFixed32(b.buf, v)
+	return b
+}
+
+func (b *Builder) SFixed32(number protowire.Number, v int32) *Builder {
+	return b.Fixed32(number, uint32(v))
+}
+
+func (b *Builder) Float32(number protowire.Number, v float32) *Builder {
+	return b.Fixed32(number, math.Float32bits(v))
+}
+
+func (b *Builder) Fixed64(number protowire.Number, v uint64) *Builder {
+	b.buf = protowire.AppendTag(b.buf, number, protowire.Fixed64Type)
+	b.buf = protowire.AppendFixed64(b.buf, v)
+	return b
+}
+
+func (b *Builder) SFixed64(number protowire.Number, v int64) *Builder {
+	return b.Fixed64(number, uint64(v))
+}
+
+func (b *Builder) Float64(number protowire.Number, v float64) *Builder {
+	return b.Fixed64(number, math.Float64bits(v))
+}
+
+func (b *Builder) String(number protowire.Number, v string) *Builder {
+	b.buf = protowire.AppendTag(b.buf, number, protowire.BytesType)
+	b.buf = protowire.AppendString(b.buf, v)
+	return b
+}
+
+// Bytes appends a length-delimited field, which is also used for encoded messages and packed
+// repeated fields.
+func (b *Builder) Bytes(number protowire.Number, v []byte) *Builder {
+	b.buf = protowire.AppendTag(b.buf, number, protowire.BytesType)
+	b.buf = protowire.AppendBytes(b.buf, v)
+	return b
+}
+
+// Value appends a field of the value, e.g. one copied from another message by ValueOfResult.
+func (b *Builder) Value(number protowire.Number, v Value) *Builder {
+	if err := v.check(); err != nil {
+		b.fail(err)
+		return b
+	}
+	b.buf = v.appendField(b.buf, number)
+	return b
+}
+
+// StartMessage starts an embedded message, the fields appended until the matching End are
+// written into it.
+func (b *Builder) StartMessage(number protowire.Number) *Builder {
+	b.buf = protowire.AppendTag(b.buf, number, protowire.BytesType)
+	// the length prefix is reserved, and moved if it takes more than 1 byte
+	b.buf = append(b.buf, 0)
+	b.open = append(b.open, openField{number: number, wireType: protowire.BytesType, start: len(b.buf)})
+	return b
+}
+
+// StartGroup starts a group, the fields appended until the matching End are written into it.
+func (b *Builder) StartGroup(number protowire.Number) *Builder {
+	b.buf = protowire.AppendTag(b.buf, number, protowire.StartGroupType)
+	b.open = append(b.open, openField{number: number, wireType: protowire.StartGroupType, start: len(b.buf)})
+	return b
+}
+
+// End ends the message or group started last.
+func (b *Builder) End() *Builder {
+	if len(b.open) == 0 {
+		b.fail(errors.WithMessage(ErrUnbalanced, "end without start"))
+		return b
+	}
+	f := b.open[len(b.open)-1]
+	b.open = b.open[:len(b.open)-1]
+	if f.wireType == protowire.StartGroupType {
+		b.buf = protowire.AppendTag(b.buf, f.number, protowire.EndGroupType)
+		return b
+	}
+	length := len(b.buf) - f.start
+	if prefix := protowire.SizeVarint(uint64(length)); prefix > 1 {
+		b.buf = append(b.buf, make([]byte, prefix-1)...)
+		copy(b.buf[f.start+prefix-1:], b.buf[f.start:f.start+length])
+	}
+	protowire.AppendVarint(b.buf[f.start-1:f.start-1], uint64(length))
+	return b
+}
+
+// Message appends an embedded message of the fields appended by fn.
+func (b *Builder) Message(number protowire.Number, fn func(b *Builder)) *Builder {
+	b.StartMessage(number)
+	fn(b)
+	return b.End()
+}
+
+// Group appends a group of the fields appended by fn.
+func (b *Builder) Group(number protowire.Number, fn func(b *Builder)) *Builder {
+	b.StartGroup(number)
+	fn(b)
+	return b.End()
+}
+
+// Len returns the number of bytes appended so far.
+func (b *Builder) Len() int {
+	return len(b.buf)
+}
+
+// Build returns the message built, an error is returned if any message or group is not ended,
+// or any field appended is invalid.
+func (b *Builder) Build() ([]byte, error) {
+	if b.err != nil {
+		return nil, b.err
+	}
+	if len(b.open) > 0 {
+		f := b.open[len(b.open)-1]
+		return nil, errors.WithMessagef(ErrUnbalanced, "field %d is not ended", f.number)
+	}
+	return b.buf, nil
+}
+
+// Reset empties the builder to be reused, the buffer is kept.
+func (b *Builder) Reset() {
+	*b = Builder{buf: b.buf[:0], open: b.open[:0]}
+}
+
+func (b *Builder) varint(number protowire.Number, v uint64) *Builder {
+	b.buf = protowire.AppendTag(b.buf, number, protowire.VarintType)
+	b.buf = protowire.AppendVarint(b.buf, v)
+	return b
+}
+
+// fail records the first error.
+func (b *Builder) fail(err error) {
+	if b.err == nil {
+		b.err = err
+	}
+}
@@ -0,0 +1,126 @@
+package gpb
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/ywx217/gpb/internal/testprotos"
+	"github.com/ywx217/gpb/protoscope"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+)
+
+// buildGoTest builds the same message as initGoTest.
+func buildGoTest(b *Builder, setDefaults bool) *Builder {
+	b.Int32(1, int32(testprotos.GoTest_TIME)).
+		Message(4, func(b *Builder) {
+			b.String(1, "label").String(2, "type")
+		}).
+		Bool(10, true).
+		Int32(11, 3).
+		Int64(12, 6).
+		Fixed32(13, 32).
+		Fixed64(14, 64).
+		Uint32(15, 3232).
+		Uint64(16, 6464).
+		Float32(17, 3232).
+		Float64(18, 6464).
+		String(19, "string")
+	if setDefaults {
+		b.Bool(40, testprotos.Default_GoTest_F_BoolDefaulted).
+			Int32(41, testprotos.Default_GoTest_F_Int32Defaulted).
+			Int64(42, testprotos.Default_GoTest_F_Int64Defaulted).
+			Fixed32(43, testprotos.Default_GoTest_F_Fixed32Defaulted).
+			Fixed64(44, testprotos.Default_GoTest_F_Fixed64Defaulted).
+			Uint32(45, testprotos.Default_GoTest_F_Uint32Defaulted).
+			Uint64(46, testprotos.Default_GoTest_F_Uint64Defaulted).
+			Float32(47, testprotos.Default_GoTest_F_FloatDefaulted).
+			Float64(48, testprotos.Default_GoTest_F_DoubleDefaulted).
+			String(49, testprotos.Default_GoTest_F_StringDefaulted)
+	}
+	b.StartGroup(70).String(71, "required").End().
+		Bytes(101, []byte("bytes")).
+		Sint32(102, -32).
+		Sint64(103, -64).
+		SFixed32(104, -32).
+		SFixed64(105, -64)
+	if setDefaults {
+		b.Bytes(401, testprotos.Default_GoTest_F_BytesDefaulted).
+			Sint32(402, testprotos.Default_GoTest_F_Sint32Defaulted).
+			Sint64(403, testprotos.Default_GoTest_F_Sint64Defaulted).
+			SFixed32(404, testprotos.Default_GoTest_F_Sfixed32Defaulted).
+			SFixed64(405, testprotos.Default_GoTest_F_Sfixed64Defaulted)
+	}
+	return b
+}
+
+func TestBuilder(t *testing.T) {
+	for _, setDefaults := range []bool{false, true} {
+		expected, err := proto.Marshal(initGoTest(setDefaults))
+		require.NoError(t, err)
+		pb, err := buildGoTest(NewBuilder(nil), setDefaults).Build()
+		require.NoError(t, err)
+		require.Equal(t, expected, pb)
+	}
+
+	// repeated fields, packed fields and long messages
+	msg := initGoTest(false)
+	msg.RepeatedField = []*testprotos.GoTestField{
+		{Label: proto.String(strings.Repeat("l", 200)), Type: proto.String("t0")},
+		{Label: proto.String("l1"), Type: proto.String(strings.Repeat("t", 20000))},
+	}
+	msg.F_Int32Repeated = []int32{32, 33, 34}
+	msg.F_Sint32RepeatedPacked = []int32{-1, 2}
+	msg.Repeatedgroup = []*testprotos.GoTest_RepeatedGroup{initGoTestRepeatedGroup()}
+	msg.Optionalgroup = initGoTestOptionalGroup()
+	expected, err := proto.Marshal(msg)
+	require.NoError(t, err)
+
+	b := NewBuilder(nil)
+	b.Int32(1, int32(testprotos.GoTest_TIME)).
+		Message(4, func(b *Builder) {
+			b.String(1, "label").String(2, "type")
+		}).
+		Message(5, func(b *Builder) {
+			b.String(1, strings.Repeat("l", 200)).String(2, "t0")
+		}).
+		StartMessage(5).String(1, "l1").String(2, strings.Repeat("t", 20000)).End().
+		Bool(10, true).Int32(11, 3).Int64(12, 6).Fixed32(13, 32).Fixed64(14, 64).
+		Uint32(15, 3232).Uint64(16, 6464).Float32(17, 3232).Float64(18, 6464).String(19, "string").
+		Int32(21, 32).Int32(21, 33).Int32(21, 34).
+		Group(70, func(b *Builder) { b.String(71, "required") }).
+		Group(80, func(b *Builder) { b.String(81, "repeated") }).
+		Group(90, func(b *Builder) { b.String(91, "optional") }).
+		Value(101, ValueOfBytes([]byte("bytes"))).
+		Sint32(102, -32).Sint64(103, -64).SFixed32(104, -32).SFixed64(105, -64).
+		Bytes(502, protoscope.MustParse(`1 4`))
+	pb, err := b.Build()
+	require.NoError(t, err)
+	require.Equal(t, expected, pb)
+	require.Equal(t, len(pb), b.Len())
+
+	// reused after reset
+	b.Reset()
+	pb, err = buildGoTest(b, false).Build()
+	require.NoError(t, err)
+	require.Equal(t, GetOne(pb, 4, 1).String(), "label")
+}
+
+func TestBuilderAppend(t *testing.T) {
+	pb, err := NewBuilder([]byte("prefix")).StartMessage(1).StartMessage(2).End().End().Build()
+	require.NoError(t, err)
+	require.Equal(t, append([]byte("prefix"), protoscope.MustParse(`1: {2: {}}`)...), pb)
+}
+
+func TestBuilderError(t *testing.T) {
+	_, err := NewBuilder(nil).StartMessage(1).StartGroup(2).End().Build()
+	require.ErrorIs(t, err, ErrUnbalanced)
+	require.ErrorContains(t, err, "field 1")
+
+	_, err = NewBuilder(nil).Int32(1, 1).End().StartMessage(2).End().Build()
+	require.ErrorIs(t, err, ErrUnbalanced)
+
+	_, err = NewBuilder(nil).Value(1, ValueOfResult(Result{WireType: InvalidWireType})).Build()
+	require.ErrorIs(t, err, ErrUnknownWireType)
+}
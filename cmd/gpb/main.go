@@ -0,0 +1,334 @@
+// Command gpb inspects protobuf messages without generated code.
+//
+// Usage:
+//
+//	gpb get [-as kind] [-all] <path> [file]   print the values matched by the path
+//	gpb raw [-format decode_raw|hexdump|protoscope] [file]
+//	                                           print the message without schema
+//	gpb fields [file]                          list the fields of the message
+//	gpb validate [file]                        check that the message is well-formed
+//
+// Without -descriptor, validate checks the fields at the top level and in groups only, as the
+// embedded messages can not be told apart from strings and bytes without the schema.
+//
+// The message is read from the file, or stdin if the file is omitted or `-`. The common flags
+// of the subcommands:
+//
+//	-input raw|hex|base64   the encoding of the input, raw by default
+//	-descriptor file        the file descriptor set produced by `protoc --include_imports -o`
+//	-message name           the full name of the message in the descriptor set, with which the
+//	                        paths can be written with field names, e.g. `required_field.label`
+package main
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/ywx217/gpb"
+	"github.com/ywx217/gpb/protoscope"
+
+	"github.com/pkg/errors"
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+const (
+	exitOK    = 0
+	exitError = 1
+	exitUsage = 2
+)
+
+var errUsage = errors.New("usage error")
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+// command holds the flags and the streams shared by the subcommands.
+type command struct {
+	flags      *flag.FlagSet
+	input      string
+	descriptor string
+	message    string
+
+	stdin  io.Reader
+	stdout io.Writer
+	schema *gpb.Schema
+}
+
+var subcommands = map[string]func(c *command) func() error{
+	"get":      getCommand,
+	"raw":      rawCommand,
+	"fields":   fieldsCommand,
+	"validate": validateCommand,
+}
+
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	if len(args) == 0 || subcommands[args[0]] == nil {
+		fmt.Fprintln(stderr, "usage: gpb get|raw|fields|validate [flags] [args] [file]")
+		return exitUsage
+	}
+	c := &command{
+		flags:  flag.NewFlagSet("gpb "+args[0], flag.ContinueOnError),
+		stdin:  stdin,
+		stdout: stdout,
+	}
+	c.flags.SetOutput(stderr)
+	c.flags.StringVar(&c.input, "input", "raw", "encoding of the input, one of raw, hex or base64")
+	c.flags.StringVar(&c.descriptor, "descriptor", "", "file descriptor set for name paths")
+	c.flags.StringVar(&c.message, "message", "", "full name of the message in the descriptor set")
+	exec := subcommands[args[0]](c)
+	if err := c.flags.Parse(args[1:]); err != nil {
+		return exitUsage
+	}
+	err := c.loadSchema()
+	if err == nil {
+		err = exec()
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "%s: %v\n", c.flags.Name(), err)
+		if errors.Is(err, errUsage) {
+			c.flags.Usage()
+			return exitUsage
+		}
+		return exitError
+	}
+	return exitOK
+}
+
+func (c *command) loadSchema() error {
+	if c.descriptor == "" && c.message == "" {
+		return nil
+	}
+	if c.descriptor == "" || c.message == "" {
+		return errors.WithMessage(errUsage, "-descriptor and -message must be given together")
+	}
+	raw, err := os.ReadFile(c.descriptor)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	c.schema, err = gpb.ParseSchema(raw, protoreflect.FullName(c.message))
+	return err
+}
+
+// readMessage reads the message from the file at the given index of the arguments, or stdin
+// if it is omitted.
+func (c *command) readMessage(argIndex int) ([]byte, error) {
+	var data []byte
+	var err error
+	switch name := c.flags.Arg(argIndex); name {
+	case "", "-":
+		data, err = io.ReadAll(c.stdin)
+	default:
+		data, err = os.ReadFile(name)
+	}
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	switch c.input {
+	case "raw":
+		return data, nil
+	case "hex":
+		s := strings.Join(strings.Fields(string(data)), "")
+		pb, err := hex.DecodeString(strings.TrimPrefix(s, "0x"))
+		return pb, errors.Wrap(err, "invalid hex input")
+	case "base64":
+		s := strings.Join(strings.Fields(string(data)), "")
+		for _, encoding := range []*base64.Encoding{base64.StdEncoding, base64.URLEncoding, base64.RawStdEncoding, base64.RawURLEncoding} {
+			if pb, err := encoding.DecodeString(s); err == nil {
+				return pb, nil
+			}
+		}
+		return nil, errors.New("invalid base64 input")
+	default:
+		return nil, errors.WithMessagef(errUsage, "unknown input encoding %q", c.input)
+	}
+}
+
+func getCommand(c *command) func() error {
+	as := c.flags.String("as", "", "kind of the values, e.g. int32, sint64, fixed32, double, string, bytes, raw")
+	all := c.flags.Bool("all", false, "print all the values matched instead of the first one")
+	return func() error {
+		if c.flags.NArg() < 1 {
+			return errors.WithMessage(errUsage, "path is missing")
+		}
+		path := c.flags.Arg(0)
+		pb, err := c.readMessage(1)
+		if err != nil {
+			return err
+		}
+		var found bool
+		var printErr error
+		emit := func(r gpb.Result, desc protoreflect.FieldDescriptor) bool {
+			found = true
+			var line string
+			if line, printErr = format(r, desc, *as); printErr != nil {
+				return false
+			}
+			fmt.Fprintln(c.stdout, line)
+			return *all
+		}
+		if c.schema != nil {
+			err = c.schema.ForEachPath(pb, path, func(r gpb.TypedResult) bool {
+				return emit(r.Result, r.Desc)
+			})
+		} else {
+			err = gpb.ForEachPath(pb, path, func(r gpb.Result) bool {
+				return emit(r, nil)
+			})
+		}
+		if err == nil {
+			err = printErr
+		}
+		if err == nil && !found {
+			err = errors.Errorf("%s not found", path)
+		}
+		return err
+	}
+}
+
+// format formats the value as the kind, the kind of the field is used if the kind is empty.
+func format(r gpb.Result, desc protoreflect.FieldDescriptor, kind string) (string, error) {
+	if kind == "" && desc != nil {
+		kind = desc.Kind().String()
+		if desc.Kind() == protoreflect.EnumKind {
+			if name := (gpb.TypedResult{Result: r, Desc: desc}).EnumName(); name != "" {
+				return string(name), nil
+			}
+			kind = "int32"
+		}
+	}
+	if kind == "" {
+		kind = defaultKinds[r.WireType]
+		if r.WireType == protowire.BytesType && !utf8.Valid(r.Raw) {
+			kind = "bytes"
+		}
+	}
+	switch kind {
+	case "int32":
+		return strconv.FormatInt(int64(r.Int32()), 10), nil
+	case "int64":
+		return strconv.FormatInt(r.Int64(), 10), nil
+	case "uint32":
+		return strconv.FormatUint(uint64(r.Uint32()), 10), nil
+	case "uint64":
+		return strconv.FormatUint(r.Uint64(), 10), nil
+	case "sint32":
+		return strconv.FormatInt(int64(r.Sint32()), 10), nil
+	case "sint64":
+		return strconv.FormatInt(r.Sint64(), 10), nil
+	case "bool":
+		return strconv.FormatBool(r.Bool()), nil
+	case "fixed32":
+		return strconv.FormatUint(uint64(r.Fixed32()), 10), nil
+	case "sfixed32":
+		return strconv.FormatInt(int64(r.SFixed32()), 10), nil
+	case "float":
+		return strconv.FormatFloat(float64(r.Float32()), 'g', -1, 32), nil
+	case "fixed64":
+		return strconv.FormatUint(r.Fixed64(), 10), nil
+	case "sfixed64":
+		return strconv.FormatInt(r.SFixed64(), 10), nil
+	case "double":
+		return strconv.FormatFloat(r.Float64(), 'g', -1, 64), nil
+	case "string":
+		return r.String(), nil
+	case "bytes":
+		return hex.EncodeToString(r.Raw), nil
+	case "raw", "message", "group":
+		if r.WireType != protowire.BytesType && r.WireType != protowire.StartGroupType {
+			return "", errors.Errorf("field %d of wire type %d is not a message", r.Number, r.WireType)
+		}
+		s, err := gpb.DecodeRaw(r.Raw)
+		return strings.TrimSuffix(s, "\n"), err
+	default:
+		return "", errors.WithMessagef(errUsage, "unknown kind %q", kind)
+	}
+}
+
+var defaultKinds = map[protowire.Type]string{
+	protowire.VarintType:     "uint64",
+	protowire.Fixed32Type:    "fixed32",
+	protowire.Fixed64Type:    "fixed64",
+	protowire.BytesType:      "string",
+	protowire.StartGroupType: "raw",
+}
+
+func rawCommand(c *command) func() error {
+	formatName := c.flags.String("format", "decode_raw", "output format, one of decode_raw, hexdump or protoscope")
+	return func() error {
+		pb, err := c.readMessage(0)
+		if err != nil {
+			return err
+		}
+		switch *formatName {
+		case "decode_raw":
+			s, err := gpb.DecodeRaw(pb)
+			if err != nil {
+				return err
+			}
+			_, err = io.WriteString(c.stdout, s)
+			return errors.WithStack(err)
+		case "hexdump":
+			return gpb.Hexdump(c.stdout, pb)
+		case "protoscope":
+			_, err = io.WriteString(c.stdout, protoscope.Format(pb))
+			return errors.WithStack(err)
+		default:
+			return errors.WithMessagef(errUsage, "unknown format %q", *formatName)
+		}
+	}
+}
+
+func fieldsCommand(c *command) func() error {
+	return func() error {
+		pb, err := c.readMessage(0)
+		if err != nil {
+			return err
+		}
+		return gpb.ForEach(pb, func(num protowire.Number, f gpb.Result) bool {
+			line := fmt.Sprintf("%-6d %-8s offset=%d length=%d", num, gpb.WireTypeName(f.WireType), f.Start, f.End-f.Start)
+			if c.schema != nil {
+				if fd := c.schema.Descriptor().Fields().ByNumber(num); fd != nil {
+					line = fmt.Sprintf("%s %s", line, fd.Name())
+				}
+			}
+			fmt.Fprintln(c.stdout, line)
+			return true
+		})
+	}
+}
+
+func validateCommand(c *command) func() error {
+	return func() error {
+		pb, err := c.readMessage(0)
+		if err != nil {
+			return err
+		}
+		if c.schema != nil {
+			msg := dynamicpb.NewMessage(c.schema.Descriptor())
+			err = proto.Unmarshal(pb, msg)
+		} else {
+			err = gpb.ForEach(pb, func(protowire.Number, gpb.Result) bool { return true })
+		}
+		if err != nil {
+			var pe *gpb.ParseError
+			if errors.As(err, &pe) {
+				// the bytes around the malformed field
+				return errors.Errorf("%v\n%s", pe, strings.TrimSuffix(pe.Hexdump(pb, 16), "\n"))
+			}
+			return err
+		}
+		fmt.Fprintln(c.stdout, "ok")
+		return nil
+	}
+}
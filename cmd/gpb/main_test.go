@@ -0,0 +1,174 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ywx217/gpb/internal/testprotos"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+func marshalGoTest(t *testing.T) []byte {
+	msg := &testprotos.GoTest{
+		Kind:             testprotos.GoTest_TIME.Enum(),
+		RequiredField:    &testprotos.GoTestField{Label: proto.String("label"), Type: proto.String("type")},
+		RepeatedField:    []*testprotos.GoTestField{{Label: proto.String("l0")}, {Label: proto.String("l1")}},
+		F_Sint32Required: proto.Int32(-32),
+		F_BytesRequired:  []byte{0xff, 0x00},
+		Requiredgroup:    &testprotos.GoTest_RequiredGroup{RequiredField: proto.String("required")},
+	}
+	pb, err := proto.MarshalOptions{AllowPartial: true}.Marshal(msg)
+	require.NoError(t, err)
+	return pb
+}
+
+// writeDescriptorSet writes the file descriptor set of the test protos into a temporary file.
+func writeDescriptorSet(t *testing.T) string {
+	set := &descriptorpb.FileDescriptorSet{
+		File: []*descriptorpb.FileDescriptorProto{protodesc.ToFileDescriptorProto(testprotos.File_test_proto)},
+	}
+	raw, err := proto.Marshal(set)
+	require.NoError(t, err)
+	name := filepath.Join(t.TempDir(), "test.pb")
+	require.NoError(t, os.WriteFile(name, raw, 0o644))
+	return name
+}
+
+// runCommand runs the command with pb as stdin, and returns the exit code, stdout and stderr.
+func runCommand(pb []byte, args ...string) (int, string, string) {
+	var stdout, stderr bytes.Buffer
+	code := run(args, bytes.NewReader(pb), &stdout, &stderr)
+	return code, stdout.String(), stderr.String()
+}
+
+func TestGet(t *testing.T) {
+	pb := marshalGoTest(t)
+	for _, c := range []struct {
+		args     []string
+		expected string
+	}{
+		{[]string{"get", "4.1"}, "label\n"},
+		{[]string{"get", "-all", "5.1"}, "l0\nl1\n"},
+		{[]string{"get", "5.1"}, "l0\n"},
+		{[]string{"get", "102"}, "63\n"},
+		{[]string{"get", "-as", "sint32", "102"}, "-32\n"},
+		{[]string{"get", "--as", "sint64", "102"}, "-32\n"},
+		{[]string{"get", "101"}, "ff00\n"},
+		{[]string{"get", "70"}, "71: \"required\"\n"},
+		{[]string{"get", "-as", "raw", "4"}, "1: \"label\"\n2: \"type\"\n"},
+		{[]string{"get", "5.#"}, "2\n"},
+	} {
+		code, stdout, stderr := runCommand(pb, c.args...)
+		require.Equal(t, exitOK, code, stderr)
+		require.Equal(t, c.expected, stdout, c.args)
+	}
+
+	code, _, stderr := runCommand(pb, "get", "6")
+	require.Equal(t, exitError, code)
+	require.Equal(t, "gpb get: 6 not found\n", stderr)
+	code, _, _ = runCommand(pb, "get", "-as", "int128", "1")
+	require.Equal(t, exitUsage, code)
+	code, _, _ = runCommand(pb, "get")
+	require.Equal(t, exitUsage, code)
+	code, _, _ = runCommand(pb, "unknown")
+	require.Equal(t, exitUsage, code)
+}
+
+func TestGetSchema(t *testing.T) {
+	pb := marshalGoTest(t)
+	descriptor := writeDescriptorSet(t)
+	for path, expected := range map[string]string{
+		"required_field.label":        "label\n",
+		"kind":                        "TIME\n",
+		"F_Sint32_required":           "-32\n",
+		"requiredgroup.RequiredField": "required\n",
+	} {
+		code, stdout, stderr := runCommand(pb, "get", "-descriptor", descriptor, "-message", "proto2_test.GoTest", path)
+		require.Equal(t, exitOK, code, stderr)
+		require.Equal(t, expected, stdout, path)
+	}
+
+	code, _, stderr := runCommand(pb, "get", "-descriptor", descriptor, "-message", "proto2_test.Unknown", "1")
+	require.Equal(t, exitError, code)
+	require.Contains(t, stderr, "proto2_test.Unknown")
+	code, _, _ = runCommand(pb, "get", "-descriptor", descriptor, "1")
+	require.Equal(t, exitUsage, code)
+}
+
+func TestInput(t *testing.T) {
+	pb := marshalGoTest(t)
+	name := filepath.Join(t.TempDir(), "msg.hex")
+	require.NoError(t, os.WriteFile(name, []byte(hex.EncodeToString(pb)+"\n"), 0o644))
+	code, stdout, stderr := runCommand(nil, "get", "-input", "hex", "4.1", name)
+	require.Equal(t, exitOK, code, stderr)
+	require.Equal(t, "label\n", stdout)
+
+	stdin := []byte(base64.StdEncoding.EncodeToString(pb))
+	code, stdout, stderr = runCommand(stdin, "get", "-input", "base64", "4.1", "-")
+	require.Equal(t, exitOK, code, stderr)
+	require.Equal(t, "label\n", stdout)
+
+	code, _, stderr = runCommand([]byte("zz"), "get", "-input", "hex", "1")
+	require.Equal(t, exitError, code)
+	require.Contains(t, stderr, "invalid hex input")
+	code, _, _ = runCommand(nil, "get", "1", filepath.Join(t.TempDir(), "missing"))
+	require.Equal(t, exitError, code)
+}
+
+func TestRaw(t *testing.T) {
+	pb := marshalGoTest(t)
+	code, stdout, _ := runCommand(pb, "raw")
+	require.Equal(t, exitOK, code)
+	require.True(t, strings.HasPrefix(stdout, "1: 7\n4 {\n  1: \"label\"\n"), stdout)
+
+	code, stdout, _ = runCommand(pb, "raw", "-format", "protoscope")
+	require.Equal(t, exitOK, code)
+	require.True(t, strings.HasPrefix(stdout, "1: 7\n4: {\n  1: {\"label\"}\n"), stdout)
+
+	code, stdout, _ = runCommand(pb, "raw", "-format", "hexdump")
+	require.Equal(t, exitOK, code)
+	require.True(t, strings.HasPrefix(stdout, "00000000  08                       1         tag        varint\n"), stdout)
+
+	code, _, _ = runCommand(pb[:len(pb)-1], "raw")
+	require.Equal(t, exitError, code)
+}
+
+func TestFields(t *testing.T) {
+	pb := marshalGoTest(t)
+	code, stdout, _ := runCommand(pb, "fields")
+	require.Equal(t, exitOK, code)
+	lines := strings.Split(strings.TrimSuffix(stdout, "\n"), "\n")
+	require.Equal(t, "1      varint   offset=0 length=2", lines[0])
+	require.Equal(t, "4      bytes    offset=2 length=15", lines[1])
+	require.Len(t, lines, 7)
+
+	code, stdout, _ = runCommand(pb, "fields", "-descriptor", writeDescriptorSet(t), "-message", "proto2_test.GoTest")
+	require.Equal(t, exitOK, code)
+	require.True(t, strings.HasPrefix(stdout, "1      varint   offset=0 length=2 Kind\n"), stdout)
+}
+
+func TestValidate(t *testing.T) {
+	pb := marshalGoTest(t)
+	code, stdout, _ := runCommand(pb, "validate")
+	require.Equal(t, exitOK, code)
+	require.Equal(t, "ok\n", stdout)
+
+	code, _, stderr := runCommand(pb[:len(pb)-1], "validate")
+	require.Equal(t, exitError, code)
+	require.Contains(t, stderr, "offset=49 field=102 wire_type=0: invalid length")
+	require.Contains(t, stderr, "^^")
+
+	// the required fields are checked with the schema
+	code, _, stderr = runCommand(pb, "validate", "-descriptor", writeDescriptorSet(t), "-message", "proto2_test.GoTest")
+	require.Equal(t, exitError, code)
+	require.Contains(t, stderr, "required field")
+}
@@ -0,0 +1,161 @@
+package gpb
+
+import (
+	"math"
+	"unicode/utf8"
+
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// The checked accessors convert the result like the accessors of the same names, e.g. AsInt32
+// like Int32, but a *ConversionError is returned instead of a zero or truncated value when
+// the result does not exist (ErrNotFound), the result is not of the wire type of the kind
+// (ErrTypeMismatch), the varint is out of the range of the kind, the fixed-width value is
+// malformed, or the string is not valid UTF-8.
+
+func (r Result) AsInt32() (int32, error) {
+	if err := r.checkVarint(protoreflect.Int32Kind); err != nil {
+		return 0, err
+	}
+	if v := int64(r.Varint); v < math.MinInt32 || v > math.MaxInt32 {
+		return 0, r.conversionError(protoreflect.Int32Kind, ErrOutOfRange)
+	}
+	return r.Int32(), nil
+}
+
+func (r Result) AsInt64() (int64, error) {
+	if err := r.checkVarint(protoreflect.Int64Kind); err != nil {
+		return 0, err
+	}
+	return r.Int64(), nil
+}
+
+func (r Result) AsUint32() (uint32, error) {
+	if err := r.checkVarint(protoreflect.Uint32Kind); err != nil {
+		return 0, err
+	}
+	if r.Varint > math.MaxUint32 {
+		return 0, r.conversionError(protoreflect.Uint32Kind, ErrOutOfRange)
+	}
+	return r.Uint32(), nil
+}
+
+func (r Result) AsUint64() (uint64, error) {
+	if err := r.checkVarint(protoreflect.Uint64Kind); err != nil {
+		return 0, err
+	}
+	return r.Uint64(), nil
+}
+
+// AsBool accepts any varint like the protobuf runtime, non-zero values are true.
+func (r Result) AsBool() (bool, error) {
+	if err := r.checkVarint(protoreflect.BoolKind); err != nil {
+		return false, err
+	}
+	return r.Bool(), nil
+}
+
+func (r Result) AsSint32() (int32, error) {
+	if err := r.checkVarint(protoreflect.Sint32Kind); err != nil {
+		return 0, err
+	}
+	if v := protowire.DecodeZigZag(r.Varint); v < math.MinInt32 || v > math.MaxInt32 {
+		return 0, r.conversionError(protoreflect.Sint32Kind, ErrOutOfRange)
+	}
+	return r.Sint32(), nil
+}
+
+func (r Result) AsSint64() (int64, error) {
+	if err := r.checkVarint(protoreflect.Sint64Kind); err != nil {
+		return 0, err
+	}
+	return r.Sint64(), nil
+}
+
+func (r Result) AsFloat32() (float32, error) {
+	if err := r.checkFixed(protoreflect.FloatKind, protowire.Fixed32Type, 4); err != nil {
+		return 0, err
+	}
+	return r.Float32(), nil
+}
+
+func (r Result) AsFixed32() (uint32, error) {
+	if err := r.checkFixed(protoreflect.Fixed32Kind, protowire.Fixed32Type, 4); err != nil {
+		return 0, err
+	}
+	return r.Fixed32(), nil
+}
+
+func (r Result) AsSFixed32() (int32, error) {
+	if err := r.checkFixed(protoreflect.Sfixed32Kind, protowire.Fixed32Type, 4); err != nil {
+		return 0, err
+	}
+	return r.SFixed32(), nil
+}
+
+func (r Result) AsFloat64() (float64, error) {
+	if err := r.checkFixed(protoreflect.DoubleKind, protowire.Fixed64Type, 8); err != nil {
+		return 0, err
+	}
+	return r.Float64(), nil
+}
+
+func (r Result) AsFixed64() (uint64, error) {
+	if err := r.checkFixed(protoreflect.Fixed64Kind, protowire.Fixed64Type, 8); err != nil {
+		return 0, err
+	}
+	return r.Fixed64(), nil
+}
+
+func (r Result) AsSFixed64() (int64, error) {
+	if err := r.checkFixed(protoreflect.Sfixed64Kind, protowire.Fixed64Type, 8); err != nil {
+		return 0, err
+	}
+	return r.SFixed64(), nil
+}
+
+func (r Result) AsString() (string, error) {
+	if err := r.checkWireType(protoreflect.StringKind, protowire.BytesType); err != nil {
+		return "", err
+	}
+	if !utf8.Valid(r.Raw) {
+		return "", r.conversionError(protoreflect.StringKind, ErrInvalidUTF8)
+	}
+	return r.String(), nil
+}
+
+func (r Result) AsBytes() ([]byte, error) {
+	if err := r.checkWireType(protoreflect.BytesKind, protowire.BytesType); err != nil {
+		return nil, err
+	}
+	return r.Bytes(), nil
+}
+
+func (r Result) checkWireType(kind protoreflect.Kind, wireType protowire.Type) error {
+	if !r.Exist() {
+		return r.conversionError(kind, ErrNotFound)
+	}
+	if r.WireType != wireType {
+		return r.conversionError(kind, ErrTypeMismatch)
+	}
+	return nil
+}
+
+func (r Result) checkVarint(kind protoreflect.Kind) error {
+	return r.checkWireType(kind, protowire.VarintType)
+}
+
+func (r Result) checkFixed(kind protoreflect.Kind, wireType protowire.Type, size int) error {
+	if err := r.checkWireType(kind, wireType); err != nil {
+		return err
+	}
+	if len(r.Raw) != size {
+		return r.conversionError(kind, ErrInvalidLength)
+	}
+	return nil
+}
+
+func (r Result) conversionError(kind protoreflect.Kind, err error) error {
+	return &ConversionError{Number: r.Number, WireType: r.WireType, Kind: kind, Err: err}
+}
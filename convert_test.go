@@ -0,0 +1,150 @@
+package gpb
+
+import (
+	"math"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+func TestConvert(t *testing.T) {
+	msg := initGoTest(false)
+	bs, err := proto.Marshal(msg)
+	require.NoError(t, err)
+
+	requireValue := func(expected interface{}, v interface{}, err error) {
+		t.Helper()
+		require.NoError(t, err)
+		require.Equal(t, expected, v)
+	}
+	b, err := GetOne(bs, 10).AsBool()
+	requireValue(true, b, err)
+	i32, err := GetOne(bs, 11).AsInt32()
+	requireValue(msg.GetF_Int32Required(), i32, err)
+	i64, err := GetOne(bs, 12).AsInt64()
+	requireValue(msg.GetF_Int64Required(), i64, err)
+	u32, err := GetOne(bs, 13).AsFixed32()
+	requireValue(msg.GetF_Fixed32Required(), u32, err)
+	u64, err := GetOne(bs, 14).AsFixed64()
+	requireValue(msg.GetF_Fixed64Required(), u64, err)
+	u32, err = GetOne(bs, 15).AsUint32()
+	requireValue(msg.GetF_Uint32Required(), u32, err)
+	u64, err = GetOne(bs, 16).AsUint64()
+	requireValue(msg.GetF_Uint64Required(), u64, err)
+	f32, err := GetOne(bs, 17).AsFloat32()
+	requireValue(msg.GetF_FloatRequired(), f32, err)
+	f64, err := GetOne(bs, 18).AsFloat64()
+	requireValue(msg.GetF_DoubleRequired(), f64, err)
+	s, err := GetOne(bs, 19).AsString()
+	requireValue(msg.GetF_StringRequired(), s, err)
+	raw, err := GetOne(bs, 101).AsBytes()
+	requireValue(msg.GetF_BytesRequired(), raw, err)
+	i32, err = GetOne(bs, 102).AsSint32()
+	requireValue(msg.GetF_Sint32Required(), i32, err)
+	i64, err = GetOne(bs, 103).AsSint64()
+	requireValue(msg.GetF_Sint64Required(), i64, err)
+	i32, err = GetOne(bs, 104).AsSFixed32()
+	requireValue(msg.GetF_Sfixed32Required(), i32, err)
+	i64, err = GetOne(bs, 105).AsSFixed64()
+	requireValue(msg.GetF_Sfixed64Required(), i64, err)
+
+	// the bounds of the ranges
+	pb := NewBuilder(nil).
+		Int32(1, math.MinInt32).Int32(1, math.MaxInt32).
+		Uint32(2, math.MaxUint32).
+		Sint32(3, math.MinInt32).Sint32(3, math.MaxInt32).
+		Bool(4, false).Uint64(4, 2).
+		Float64(5, math.Inf(-1))
+	bs, err = pb.Build()
+	require.NoError(t, err)
+	i32, err = GetAll(bs, 1)[0].AsInt32()
+	requireValue(int32(math.MinInt32), i32, err)
+	i32, err = GetAll(bs, 1)[1].AsInt32()
+	requireValue(int32(math.MaxInt32), i32, err)
+	u32, err = GetOne(bs, 2).AsUint32()
+	requireValue(uint32(math.MaxUint32), u32, err)
+	i32, err = GetAll(bs, 3)[0].AsSint32()
+	requireValue(int32(math.MinInt32), i32, err)
+	i32, err = GetAll(bs, 3)[1].AsSint32()
+	requireValue(int32(math.MaxInt32), i32, err)
+	b, err = GetAll(bs, 4)[0].AsBool()
+	requireValue(false, b, err)
+	b, err = GetAll(bs, 4)[1].AsBool()
+	requireValue(true, b, err)
+	f64, err = GetOne(bs, 5).AsFloat64()
+	requireValue(math.Inf(-1), f64, err)
+}
+
+func TestConvertError(t *testing.T) {
+	pb := NewBuilder(nil).
+		Int64(1, math.MaxInt32+1).Int64(1, math.MinInt32-1).
+		Uint64(2, math.MaxUint32+1).
+		Sint64(3, math.MaxInt32+1).
+		String(4, "\xff").
+		Int32(5, 1)
+	bs, err := pb.Build()
+	require.NoError(t, err)
+
+	requireError := func(target error, kind protoreflect.Kind, err error) {
+		t.Helper()
+		require.ErrorIs(t, err, target)
+		var ce *ConversionError
+		require.ErrorAs(t, err, &ce)
+		require.Equal(t, kind, ce.Kind)
+		require.Equal(t, target, errors.Cause(err))
+	}
+
+	// the values are truncated by the unchecked accessors
+	require.Equal(t, int32(math.MinInt32), GetOne(bs, 1).Int32())
+	_, err = GetAll(bs, 1)[0].AsInt32()
+	requireError(ErrOutOfRange, protoreflect.Int32Kind, err)
+	_, err = GetAll(bs, 1)[1].AsInt32()
+	requireError(ErrOutOfRange, protoreflect.Int32Kind, err)
+	_, err = GetOne(bs, 2).AsUint32()
+	requireError(ErrOutOfRange, protoreflect.Uint32Kind, err)
+	_, err = GetOne(bs, 3).AsSint32()
+	requireError(ErrOutOfRange, protoreflect.Sint32Kind, err)
+	_, err = GetOne(bs, 4).AsString()
+	requireError(ErrInvalidUTF8, protoreflect.StringKind, err)
+	raw, err := GetOne(bs, 4).AsBytes()
+	require.NoError(t, err)
+	require.Equal(t, []byte("\xff"), raw)
+
+	// wire type mismatches
+	_, err = GetOne(bs, 4).AsInt64()
+	requireError(ErrTypeMismatch, protoreflect.Int64Kind, err)
+	_, err = GetOne(bs, 5).AsString()
+	requireError(ErrTypeMismatch, protoreflect.StringKind, err)
+	_, err = GetOne(bs, 5).AsBytes()
+	requireError(ErrTypeMismatch, protoreflect.BytesKind, err)
+	_, err = GetOne(bs, 5).AsFloat32()
+	requireError(ErrTypeMismatch, protoreflect.FloatKind, err)
+	_, err = GetOne(bs, 5).AsSFixed64()
+	requireError(ErrTypeMismatch, protoreflect.Sfixed64Kind, err)
+	_, err = GetOne(bs, 5).AsFixed32()
+	requireError(ErrTypeMismatch, protoreflect.Fixed32Kind, err)
+	require.NotErrorIs(t, err, ErrNotFound)
+
+	// missing fields are told apart from the mismatches
+	_, err = GetOne(bs, 6).AsUint64()
+	requireError(ErrNotFound, protoreflect.Uint64Kind, err)
+	require.NotErrorIs(t, err, ErrTypeMismatch)
+	require.EqualError(t, err, "field=0 wire_type=-1 as uint64: field not found")
+	_, err = GetOne(bs, 6).AsString()
+	requireError(ErrNotFound, protoreflect.StringKind, err)
+	_, err = GetOne(bs, 6).AsFixed64()
+	requireError(ErrNotFound, protoreflect.Fixed64Kind, err)
+	_, err = GetOne(bs, 5).AsSint64()
+	require.NoError(t, err)
+
+	// malformed fixed-width values
+	_, err = Result{WireType: protowire.Fixed32Type, Raw: []byte{1, 2}, Number: 7}.AsFixed32()
+	requireError(ErrInvalidLength, protoreflect.Fixed32Kind, err)
+	require.EqualError(t, err, "field=7 wire_type=5 as fixed32: invalid length")
+	_, err = Result{WireType: protowire.Fixed64Type, Raw: []byte{1}}.AsFloat64()
+	requireError(ErrInvalidLength, protoreflect.DoubleKind, err)
+}
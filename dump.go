@@ -0,0 +1,146 @@
+package gpb
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/pkg/errors"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// dumpWidth the number of bytes in a line of Hexdump
+const dumpWidth = 8
+
+var wireTypeNames = [...]string{
+	protowire.VarintType:     "varint",
+	protowire.Fixed64Type:    "fixed64",
+	protowire.BytesType:      "bytes",
+	protowire.StartGroupType: "group",
+	protowire.EndGroupType:   "end group",
+	protowire.Fixed32Type:    "fixed32",
+}
+
+// WireTypeName returns the name of the wire type printed by Hexdump, e.g. "varint", empty string
+// is returned for the unknown wire types.
+func WireTypeName(wireType protowire.Type) string {
+	if wireType < 0 || int(wireType) >= len(wireTypeNames) {
+		return ""
+	}
+	return wireTypeNames[wireType]
+}
+
+// Hexdump prints the bytes of the message side by side with the field paths, the wire types and
+// the decoded values. The tag, the length prefix and the payload of a field are printed in
+// separate lines, e.g.
+//
+//	00000000  08                       1         tag        varint
+//	00000001  96 01                    1         varint     150
+//	00000003  22                       4         tag        bytes
+//	00000004  03                       4         length     3
+//	00000005  0a                       4.1       tag        bytes
+//	00000006  01                       4.1       length     1
+//	00000007  61                       4.1       string     "a"
+//
+// Groups and the length-delimited fields holding well-formed fields only are descended into if
+// nested no deeper than the recursion limit of protoc, the same as DecodeRaw, and the deeper ones
+// are printed as bytes. The bytes after a malformed field are printed as they are, and the error
+// is returned.
+func Hexdump(w io.Writer, pb []byte) error {
+	d := dumper{w: w, root: pb}
+	err := Walk(pb, &d)
+	if err != nil && d.err == nil {
+		d.dump(d.end, len(pb), "", "malformed", err.Error())
+	}
+	if d.err != nil {
+		return d.err
+	}
+	return err
+}
+
+// dumper is the Visitor printing the fields, end is the end of the last top level field printed.
+type dumper struct {
+	w    io.Writer
+	root []byte
+	end  int
+	err  error
+}
+
+func (d *dumper) EnterMessage(path []protowire.Number, f Result) bool {
+	if len(path) > rawRecursionLimit || (f.WireType == protowire.BytesType && !isRawMessage(f.Raw)) {
+		return false
+	}
+	d.header(path, f)
+	return true
+}
+
+func (d *dumper) LeaveMessage(path []protowire.Number, f Result) {
+	if f.WireType == protowire.StartGroupType {
+		d.dump(f.rawStart()+len(f.Raw), f.End, formatNumbers(path), "tag", "end group")
+	}
+	d.leave(path, f)
+}
+
+func (d *dumper) Scalar(path []protowire.Number, f Result) {
+	d.header(path, f)
+	p := formatNumbers(path)
+	start, end := f.rawStart(), f.rawStart()+len(f.Raw)
+	switch f.WireType {
+	case protowire.VarintType:
+		d.dump(start, end, p, "varint", fmt.Sprint(f.Varint))
+	case protowire.Fixed32Type:
+		d.dump(start, end, p, "fixed32", fmt.Sprint(f.Fixed32()))
+	case protowire.Fixed64Type:
+		d.dump(start, end, p, "fixed64", fmt.Sprint(f.Fixed64()))
+	default:
+		if utf8.Valid(f.Raw) {
+			d.dump(start, end, p, "string", fmt.Sprintf("%q", f.Raw))
+		} else {
+			d.dump(start, end, p, "bytes", "")
+		}
+		if f.WireType == protowire.StartGroupType {
+			d.dump(end, f.End, p, "tag", "end group")
+		}
+	}
+	d.leave(path, f)
+}
+
+// header prints the tag and the length prefix of the field.
+func (d *dumper) header(path []protowire.Number, f Result) {
+	p := formatNumbers(path)
+	tagEnd := f.Start + len(f.Tag(d.root))
+	d.dump(f.Start, tagEnd, p, "tag", WireTypeName(f.WireType))
+	if f.WireType == protowire.BytesType {
+		d.dump(tagEnd, f.rawStart(), p, "length", fmt.Sprint(len(f.Raw)))
+	}
+}
+
+func (d *dumper) leave(path []protowire.Number, f Result) {
+	if len(path) == 1 {
+		d.end = f.End
+	}
+}
+
+// dump prints the bytes in [start, end) with the annotation in the first line.
+func (d *dumper) dump(start, end int, path, kind, value string) {
+	for line := start; line < end && d.err == nil; line += dumpWidth {
+		lineEnd := line + dumpWidth
+		if lineEnd > end {
+			lineEnd = end
+		}
+		var hex strings.Builder
+		for i := line; i < lineEnd; i++ {
+			if i > line {
+				hex.WriteByte(' ')
+			}
+			fmt.Fprintf(&hex, "%02x", d.root[i])
+		}
+		s := fmt.Sprintf("%08x  %-23s", line, hex.String())
+		if line == start {
+			s = fmt.Sprintf("%s  %-9s %-10s %s", s, path, kind, value)
+		}
+		_, err := io.WriteString(d.w, strings.TrimRight(s, " ")+"\n")
+		d.err = errors.WithStack(err)
+	}
+}
@@ -0,0 +1,131 @@
+package gpb
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ywx217/gpb/protoscope"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+type failingWriter struct{}
+
+func (failingWriter) Write([]byte) (int, error) {
+	return 0, errors.New("write failed")
+}
+
+func TestHexdump(t *testing.T) {
+	pb := protoscope.MustParse("1: 150 5: -1i32 70: !{71: {\"required\"} 72: {`ff00`}}")
+	var sb strings.Builder
+	require.NoError(t, Hexdump(&sb, pb))
+	require.Equal(t, ""+
+		"00000000  08                       1         tag        varint\n"+
+		"00000001  96 01                    1         varint     150\n"+
+		"00000003  2d                       5         tag        fixed32\n"+
+		"00000004  ff ff ff ff              5         fixed32    4294967295\n"+
+		"00000008  b3 04                    70        tag        group\n"+
+		"0000000a  ba 04                    70.71     tag        bytes\n"+
+		"0000000c  08                       70.71     length     8\n"+
+		"0000000d  72 65 71 75 69 72 65 64  70.71     string     \"required\"\n"+
+		"00000015  c2 04                    70.72     tag        bytes\n"+
+		"00000017  02                       70.72     length     2\n"+
+		"00000018  ff 00                    70.72     bytes\n"+
+		"0000001a  b4 04                    70        tag        end group\n",
+		sb.String())
+
+	// nested messages and long payloads
+	pb = protoscope.MustParse(`4: {1: {"label"} 2: {"a long string"}}`)
+	sb.Reset()
+	require.NoError(t, Hexdump(&sb, pb))
+	require.Equal(t, ""+
+		"00000000  22                       4         tag        bytes\n"+
+		"00000001  16                       4         length     22\n"+
+		"00000002  0a                       4.1       tag        bytes\n"+
+		"00000003  05                       4.1       length     5\n"+
+		"00000004  6c 61 62 65 6c           4.1       string     \"label\"\n"+
+		"00000009  12                       4.2       tag        bytes\n"+
+		"0000000a  0d                       4.2       length     13\n"+
+		"0000000b  61 20 6c 6f 6e 67 20 73  4.2       string     \"a long string\"\n"+
+		"00000013  74 72 69 6e 67\n",
+		sb.String())
+}
+
+func TestHexdumpMalformed(t *testing.T) {
+	pb := protoscope.MustParse("1: 150 3: !{ 1: 1 `0a05` }")
+	var sb strings.Builder
+	err := Hexdump(&sb, pb)
+	require.ErrorIs(t, err, ErrInvalidLength)
+	require.Equal(t, ""+
+		"00000000  08                       1         tag        varint\n"+
+		"00000001  96 01                    1         varint     150\n"+
+		"00000003  1b 08 01 0a 05 1c                  malformed  offset=6 path=3 field=1 wire_type=2: invalid length\n",
+		sb.String())
+
+	require.EqualError(t, Hexdump(failingWriter{}, pb), "write failed")
+}
+
+// deepMessage builds the same message as nestedMessage in linear time, for the deep levels.
+func deepMessage(levels int) []byte {
+	// sizes[i] the length of the payload at level i from the outermost
+	sizes := make([]int, levels)
+	size := len("x")
+	for i := levels - 1; i >= 0; i-- {
+		sizes[i] = size
+		size += protowire.SizeTag(1) + protowire.SizeVarint(uint64(size))
+	}
+	pb := make([]byte, 0, size)
+	for _, size := range sizes {
+		pb = protowire.AppendTag(pb, 1, protowire.BytesType)
+		pb = protowire.AppendVarint(pb, uint64(size))
+	}
+	return append(pb, 'x')
+}
+
+func TestHexdumpDeep(t *testing.T) {
+	require.Equal(t, nestedMessage(300), deepMessage(300))
+
+	// the messages deeper than the recursion limit are printed as bytes
+	pb := deepMessage(200000)
+	var sb strings.Builder
+	require.NoError(t, Hexdump(&sb, pb))
+	lines := strings.Split(strings.TrimSuffix(sb.String(), "\n"), "\n")
+	deepest := strings.Repeat("1.", rawRecursionLimit) + "1"
+	path := make([]protowire.Number, rawRecursionLimit+1)
+	for i := range path {
+		path[i] = 1
+	}
+	payload := len(GetOne(pb, path...).Raw)
+	require.Len(t, lines, 2*len(path)+(payload+dumpWidth-1)/dumpWidth)
+	require.Contains(t, lines[2*rawRecursionLimit], deepest+" tag")
+	require.Contains(t, lines[2*rawRecursionLimit+1], deepest+" length")
+	require.Contains(t, lines[2*rawRecursionLimit+2], deepest+" bytes")
+}
+
+func TestHexdumpDeepGroups(t *testing.T) {
+	pb := deepGroups(maxGroupDepth)
+	start := time.Now()
+	var sb strings.Builder
+	require.NoError(t, Hexdump(&sb, pb))
+	require.Less(t, time.Since(start), deepGroupsTimeout)
+
+	// the groups deeper than the recursion limit are printed as bytes
+	lines := strings.Split(strings.TrimSuffix(sb.String(), "\n"), "\n")
+	deepest := strings.Repeat("1.", rawRecursionLimit) + "1"
+	body := 2 * (maxGroupDepth - rawRecursionLimit - 1)
+	require.Len(t, lines, 2*(rawRecursionLimit+1)+(body+dumpWidth-1)/dumpWidth)
+	require.Contains(t, lines[rawRecursionLimit], deepest+" tag        group")
+	require.Contains(t, lines[rawRecursionLimit+1], deepest+" string")
+	require.Contains(t, lines[len(lines)-rawRecursionLimit-1], deepest+" tag        end group")
+}
+
+func TestWireTypeName(t *testing.T) {
+	require.Equal(t, "varint", WireTypeName(protowire.VarintType))
+	require.Equal(t, "end group", WireTypeName(protowire.EndGroupType))
+	require.Equal(t, "fixed32", WireTypeName(protowire.Fixed32Type))
+	require.Equal(t, "", WireTypeName(6))
+	require.Equal(t, "", WireTypeName(InvalidWireType))
+}
@@ -0,0 +1,63 @@
+package gpb
+
+import (
+	"github.com/pkg/errors"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// Set sets the field at the path to the value, and returns the new message. The messages along
+// the path are created if missing, and the occurrences of a message are edited as the single one
+// merged when decoded: the field is removed from all of them, and the value is written into the
+// last one. All the occurrences of the field are replaced by a single one, which is written at
+// the position of the first occurrence, or at the end of the message if it is missing. So the
+// field is removed from all the elements of a repeated message along the path, use Patch.SetPath
+// to select the element, e.g. `5.#1.1`.
+//
+// Only the bytes of the field are rewritten, along with the length prefixes of the messages
+// enclosing it, e.g. `gpb.Set(pb, gpb.ValueOfString("label"), 4, 1)`. Use Patch to apply
+// multiple edits in a single pass.
+func Set(pb []byte, value Value, pbNumbers ...protowire.Number) ([]byte, error) {
+	return SetInto(nil, pb, value, pbNumbers...)
+}
+
+// SetInto is Set, but the new message is appended to dst, which must not overlap pb.
+func SetInto(dst, pb []byte, value Value, pbNumbers ...protowire.Number) ([]byte, error) {
+	var p Patch
+	return p.Set(value, pbNumbers...).ApplyInto(dst, pb)
+}
+
+// Delete removes all the occurrences of the field at the path, in all the occurrences of the
+// messages along the path, and returns the new message. Nothing is changed if the field is
+// missing.
+func Delete(pb []byte, pbNumbers ...protowire.Number) ([]byte, error) {
+	return DeleteInto(nil, pb, pbNumbers...)
+}
+
+// DeleteInto is Delete, but the new message is appended to dst, which must not overlap pb.
+func DeleteInto(dst, pb []byte, pbNumbers ...protowire.Number) ([]byte, error) {
+	var p Patch
+	return p.Delete(pbNumbers...).ApplyInto(dst, pb)
+}
+
+// Append appends an occurrence of the field at the path, which is a new element of repeated
+// fields, and returns the new message. The messages along the path are found and created as Set,
+// and the value is appended into the last occurrence of the message.
+func Append(pb []byte, value Value, pbNumbers ...protowire.Number) ([]byte, error) {
+	return AppendInto(nil, pb, value, pbNumbers...)
+}
+
+// AppendInto is Append, but the new message is appended to dst, which must not overlap pb.
+func AppendInto(dst, pb []byte, value Value, pbNumbers ...protowire.Number) ([]byte, error) {
+	var p Patch
+	return p.Append(value, pbNumbers...).ApplyInto(dst, pb)
+}
+
+// check reports an error for the values which can not be written.
+func (v Value) check() error {
+	switch v.wireType {
+	case protowire.VarintType, protowire.Fixed32Type, protowire.Fixed64Type, protowire.BytesType, protowire.StartGroupType:
+		return nil
+	default:
+		return errors.WithMessagef(ErrUnknownWireType, "invalid value of wire type %d", v.wireType)
+	}
+}
@@ -0,0 +1,182 @@
+package gpb
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/ywx217/gpb/internal/testprotos"
+	"github.com/ywx217/gpb/protoscope"
+
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+)
+
+func unmarshalGoTest(t *testing.T, pb []byte) *testprotos.GoTest {
+	msg := &testprotos.GoTest{}
+	require.NoError(t, proto.UnmarshalOptions{AllowPartial: true}.Unmarshal(pb, msg))
+	return msg
+}
+
+func TestSet(t *testing.T) {
+	bs := marshalRepeatedGoTest(t)
+	original := append([]byte{}, bs...)
+
+	pb, err := Set(bs, ValueOfString("new label"), 4, 1)
+	require.NoError(t, err)
+	require.Equal(t, original, bs, "the message is not modified")
+	msg := unmarshalGoTest(t, pb)
+	require.Equal(t, "new label", msg.GetRequiredField().GetLabel())
+	require.Equal(t, "type", msg.GetRequiredField().GetType())
+	require.Len(t, msg.RepeatedField, 3)
+
+	// the length prefixes take more bytes
+	long := strings.Repeat("x", 300)
+	pb, err = Set(bs, ValueOfString(long), 4, 1)
+	require.NoError(t, err)
+	require.Equal(t, long, unmarshalGoTest(t, pb).GetRequiredField().GetLabel())
+	pb, err = Set(pb, ValueOfString("l"), 4, 1)
+	require.NoError(t, err)
+	require.Equal(t, "l", unmarshalGoTest(t, pb).GetRequiredField().GetLabel())
+
+	pb, err = Set(bs, ValueOfSint32(-3), 102)
+	require.NoError(t, err)
+	require.Equal(t, int32(-3), unmarshalGoTest(t, pb).GetF_Sint32Required())
+
+	// all the occurrences are replaced by one
+	pb, err = Set(bs, ValueOfInt32(-1), 21)
+	require.NoError(t, err)
+	require.Equal(t, []int32{-1}, unmarshalGoTest(t, pb).F_Int32Repeated)
+
+	// an element of a repeated message is selected by the path
+	labels := func(pb []byte) []string {
+		return lo.Map(unmarshalGoTest(t, pb).RepeatedField, func(f *testprotos.GoTestField, _ int) string {
+			return f.GetLabel()
+		})
+	}
+	expected := labels(bs)
+	expected[1] = "l"
+	pb, err = NewPatch().SetPath(ValueOfString("l"), "5.#1.1").Apply(bs)
+	require.NoError(t, err)
+	require.Equal(t, expected, labels(pb))
+
+	// groups
+	pb, err = Set(bs, ValueOfString("group"), 70, 71)
+	require.NoError(t, err)
+	require.Equal(t, "group", unmarshalGoTest(t, pb).GetRequiredgroup().GetRequiredField())
+}
+
+func TestSetConcatenated(t *testing.T) {
+	a, err := proto.MarshalOptions{AllowPartial: true}.Marshal(&testprotos.GoTest{RequiredField: &testprotos.GoTestField{Label: proto.String("a"), Type: proto.String("ta")}})
+	require.NoError(t, err)
+	b, err := proto.MarshalOptions{AllowPartial: true}.Marshal(&testprotos.GoTest{RequiredField: &testprotos.GoTestField{Label: proto.String("b")}})
+	require.NoError(t, err)
+	// the occurrences of required_field are merged by proto.Unmarshal
+	bs := append(append([]byte{}, a...), b...)
+	require.Equal(t, "b", unmarshalGoTest(t, bs).GetRequiredField().GetLabel())
+
+	pb, err := Set(bs, ValueOfString("new"), 4, 1)
+	require.NoError(t, err)
+	msg := unmarshalGoTest(t, pb)
+	require.Equal(t, "new", msg.GetRequiredField().GetLabel())
+	require.Equal(t, "ta", msg.GetRequiredField().GetType())
+	require.Equal(t, "new", GetLast(pb, 4, 1).String())
+	require.Equal(t, []string{"new"}, lo.Map(GetAll(pb, 4, 1), func(r Result, _ int) string {
+		return r.String()
+	}))
+
+	pb, err = Delete(bs, 4, 1)
+	require.NoError(t, err)
+	msg = unmarshalGoTest(t, pb)
+	require.Nil(t, msg.GetRequiredField().Label)
+	require.Equal(t, "ta", msg.GetRequiredField().GetType())
+	require.False(t, GetOne(pb, 4, 1).Exist())
+	require.Equal(t, 2, len(GetAll(pb, 4)), "the occurrences are kept")
+
+	// appended after the fields of all the occurrences
+	pb, err = Append(protoscope.MustParse(`1: {2: 1} 3: 3 1: {2: 2}`), ValueOfInt32(4), 1, 2)
+	require.NoError(t, err)
+	require.Equal(t, protoscope.MustParse(`1: {2: 1} 3: 3 1: {2: 2 2: 4}`), pb)
+	pb, err = Set(protoscope.MustParse(`1: {2: 1} 3: 3 1: !{2: 2}`), ValueOfInt32(4), 1, 2)
+	require.NoError(t, err)
+	require.Equal(t, protoscope.MustParse(`1: {} 3: 3 1: !{2: 4}`), pb)
+}
+
+func TestSetCreate(t *testing.T) {
+	pb, err := Set(nil, ValueOfString("label"), 4, 1)
+	require.NoError(t, err)
+	require.Equal(t, protoscope.MustParse(`4: {1: {"label"}}`), pb)
+
+	pb, err = Set(protoscope.MustParse(`1: 1 3: {2: 2}`), ValueOfFixed32(5), 3, 4, 5)
+	require.NoError(t, err)
+	require.Equal(t, protoscope.MustParse(`1: 1 3: {2: 2 4: {5: 5i32}}`), pb)
+
+	dst := []byte("prefix")
+	pb, err = SetInto(dst, protoscope.MustParse(`1: 1`), ValueOfBool(true), 2)
+	require.NoError(t, err)
+	require.Equal(t, append([]byte("prefix"), protoscope.MustParse(`1: 1 2: 1`)...), pb)
+}
+
+func TestSetError(t *testing.T) {
+	pb := protoscope.MustParse(`1: 1 4: {1: 2}`)
+	_, err := Set(pb, ValueOfInt32(1), 1, 2)
+	require.ErrorIs(t, err, ErrInvalidPath)
+	_, err = Set(pb, ValueOfInt32(1))
+	require.ErrorIs(t, err, ErrInvalidPath)
+	_, err = Set(pb, ValueOfResult(Result{WireType: InvalidWireType}), 1)
+	require.ErrorIs(t, err, ErrUnknownWireType)
+
+	pb = malformedNested()
+	dst, err := SetInto([]byte{1}, pb, ValueOfInt32(1), 4, 2)
+	require.Equal(t, []byte{1}, dst)
+	require.ErrorIs(t, err, ErrInvalidLength)
+	pe := requireParseError(t, err)
+	require.Equal(t, 4, pe.Offset)
+	require.Equal(t, []protowire.Number{4}, pe.Path)
+}
+
+func TestDelete(t *testing.T) {
+	bs := marshalRepeatedGoTest(t)
+	pb, err := Delete(bs, 5)
+	require.NoError(t, err)
+	msg := unmarshalGoTest(t, pb)
+	require.Empty(t, msg.RepeatedField)
+	require.Equal(t, "label", msg.GetRequiredField().GetLabel())
+
+	pb, err = Delete(bs, 4, 2)
+	require.NoError(t, err)
+	require.Nil(t, unmarshalGoTest(t, pb).GetRequiredField().Type)
+
+	// nothing is created for missing fields
+	pb, err = Delete(bs, 6, 1)
+	require.NoError(t, err)
+	require.Equal(t, bs, pb)
+
+	pb, err = Delete(protoscope.MustParse(`1: 1 3: !{2: 2 1: 1} 1: 2`), 3, 2)
+	require.NoError(t, err)
+	require.Equal(t, protoscope.MustParse(`1: 1 3: !{1: 1} 1: 2`), pb)
+}
+
+func TestAppend(t *testing.T) {
+	bs := marshalRepeatedGoTest(t)
+	field, err := proto.Marshal(&testprotos.GoTestField{Label: proto.String("l3"), Type: proto.String("t3")})
+	require.NoError(t, err)
+	pb, err := Append(bs, ValueOfBytes(field), 5)
+	require.NoError(t, err)
+	msg := unmarshalGoTest(t, pb)
+	require.Len(t, msg.RepeatedField, 4)
+	require.Equal(t, "l3", msg.RepeatedField[3].GetLabel())
+
+	pb, err = Append(bs, ValueOfInt32(35), 21)
+	require.NoError(t, err)
+	require.Equal(t, []int32{32, 33, 34, 35}, unmarshalGoTest(t, pb).F_Int32Repeated)
+
+	pb, err = Append(nil, ValueOfGroup(protoscope.MustParse(`81: {"repeated"}`)), 80)
+	require.NoError(t, err)
+	require.Equal(t, "repeated", unmarshalGoTest(t, pb).Repeatedgroup[0].GetRequiredField())
+
+	pb, err = AppendInto([]byte{0}, nil, ValueOfResult(GetOne(bs, 4)), 6, 4)
+	require.NoError(t, err)
+	require.Equal(t, append([]byte{0}, protoscope.MustParse(`6: {4: {1: {"label"} 2: {"type"}}}`)...), pb)
+}
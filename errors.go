@@ -0,0 +1,202 @@
+package gpb
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// ParseError describes where a message fails to be parsed. It wraps one of the sentinel errors,
+// so `errors.Is(err, gpb.ErrInvalidLength)` still works.
+type ParseError struct {
+	// Offset the absolute offset of the malformed field in the buffer given to the API
+	Offset int
+	// Path the field numbers of the messages and groups enclosing the malformed field
+	Path []protowire.Number
+	// Number the field number of the malformed field, 0 when the tag itself is malformed
+	Number protowire.Number
+	// WireType the wire type of the malformed field, InvalidWireType when the tag itself is malformed
+	WireType protowire.Type
+	// Err the sentinel error
+	Err error
+
+	at []byte // the buffer starting at the malformed field
+}
+
+// newParseError creates a ParseError of the malformed field at the offset of pb, which is located
+// later by the walkers. The fields before the offset are well-formed, so they are scanned again
+// to find the groups enclosing the malformed field.
+func newParseError(err error, pb []byte, offset int) *ParseError {
+	pe := &ParseError{WireType: InvalidWireType, Err: err, at: pb[offset:]}
+	if number, wireType, n := protowire.ConsumeTag(pe.at); n > 0 {
+		pe.Number, pe.WireType = number, wireType
+	}
+	var field Result
+	for head := pb[:offset]; len(head) > 0; {
+		number, wireType, n := protowire.ConsumeTag(head)
+		switch wireType {
+		case protowire.StartGroupType:
+			pe.Path = append(pe.Path, number)
+		case protowire.EndGroupType:
+			// the end group tags before the offset match the innermost groups, as the mismatched
+			// ones are rejected by consumeGroup, and the stray ones out of groups are ignored
+			if len(pe.Path) > 0 {
+				pe.Path = pe.Path[:len(pe.Path)-1]
+			}
+		default:
+			_, n, _ = consumeField(head, &field)
+		}
+		if n <= 0 {
+			// never happens for the fields accepted by consumeField
+			break
+		}
+		head = head[n:]
+	}
+	return pe
+}
+
+// locate fills the offset of the ParseError of the fields in r, which is in the buffer given to
+// the API as the other offsets of r are. It can be called repeatedly by the outer walkers, other
+// errors are returned as-is.
+func locate(err error, r Result) error {
+	pe, ok := err.(*ParseError)
+	if !ok {
+		return err
+	}
+	// the fields are sliced from r.Raw, so the difference of capacities is the offset in r.Raw
+	pe.Offset = r.rawStart() + cap(r.Raw) - cap(pe.at)
+	return err
+}
+
+// enclose prepends the field numbers of the enclosing messages or groups to the path of the
+// ParseError, other errors are returned as-is.
+func enclose(err error, numbers ...protowire.Number) error {
+	pe, ok := err.(*ParseError)
+	if !ok || len(numbers) == 0 {
+		return err
+	}
+	path := make([]protowire.Number, 0, len(numbers)+len(pe.Path))
+	pe.Path = append(append(path, numbers...), pe.Path...)
+	return err
+}
+
+func (e *ParseError) Error() string {
+	var sb strings.Builder
+	sb.WriteString("offset=")
+	sb.WriteString(strconv.Itoa(e.Offset))
+	if len(e.Path) > 0 {
+		sb.WriteString(" path=")
+		sb.WriteString(formatNumbers(e.Path))
+	}
+	if e.WireType != InvalidWireType {
+		sb.WriteString(" field=")
+		sb.WriteString(strconv.Itoa(int(e.Number)))
+		sb.WriteString(" wire_type=")
+		sb.WriteString(strconv.Itoa(int(e.WireType)))
+	}
+	sb.WriteString(": ")
+	sb.WriteString(e.Err.Error())
+	return sb.String()
+}
+
+// Unwrap returns the sentinel error.
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+// Cause returns the sentinel error, which is compatible with github.com/pkg/errors.
+func (e *ParseError) Cause() error {
+	return e.Err
+}
+
+// Hexdump renders the bytes of pb within window bytes around the failure offset in the format
+// of `hexdump -C`, with the failing byte marked. pb should be the buffer given to the API.
+func (e *ParseError) Hexdump(pb []byte, window int) string {
+	const width = 16
+	start, end := e.Offset-window, e.Offset+window+1
+	if start < 0 {
+		start = 0
+	}
+	if end > len(pb) {
+		end = len(pb)
+	}
+	var sb strings.Builder
+	for line := start / width * width; line < end; line += width {
+		var hex, ascii strings.Builder
+		marker := -1
+		for i := line; i < line+width; i++ {
+			if i == line+width/2 {
+				hex.WriteByte(' ')
+			}
+			if i < start || i >= end {
+				hex.WriteString("   ")
+				ascii.WriteByte(' ')
+				continue
+			}
+			if i == e.Offset {
+				marker = hex.Len() + 1
+			}
+			fmt.Fprintf(&hex, " %02x", pb[i])
+			if pb[i] >= 0x20 && pb[i] < 0x7f {
+				ascii.WriteByte(pb[i])
+			} else {
+				ascii.WriteByte('.')
+			}
+		}
+		fmt.Fprintf(&sb, "%08x %s  |%s|\n", line, hex.String(), ascii.String())
+		if marker >= 0 {
+			// the offset column takes 9 characters
+			sb.WriteString(strings.Repeat(" ", 9+marker))
+			sb.WriteString("^^\n")
+		}
+	}
+	if e.Offset >= len(pb) {
+		// the payload ends unexpectedly
+		fmt.Fprintf(&sb, "%08x  <end of buffer>\n", len(pb))
+	}
+	return sb.String()
+}
+
+// ConversionError describes why a result can not be converted by the checked accessors, e.g.
+// Result.AsInt32. It wraps one of the sentinel errors, so `errors.Is(err, gpb.ErrOutOfRange)`
+// works.
+type ConversionError struct {
+	// Number the field number of the result
+	Number protowire.Number
+	// WireType the wire type of the result, InvalidWireType when the result does not exist, for
+	// which Err is ErrNotFound
+	WireType protowire.Type
+	// Kind the kind converted into
+	Kind protoreflect.Kind
+	// Err the sentinel error
+	Err error
+}
+
+func (e *ConversionError) Error() string {
+	return fmt.Sprintf("field=%d wire_type=%d as %v: %v", e.Number, e.WireType, e.Kind, e.Err)
+}
+
+// Unwrap returns the sentinel error.
+func (e *ConversionError) Unwrap() error {
+	return e.Err
+}
+
+// Cause returns the sentinel error, which is compatible with github.com/pkg/errors.
+func (e *ConversionError) Cause() error {
+	return e.Err
+}
+
+// formatNumbers formats the field numbers in the path syntax, e.g. `4.1`.
+func formatNumbers(numbers []protowire.Number) string {
+	var sb strings.Builder
+	for i, number := range numbers {
+		if i > 0 {
+			sb.WriteByte(pathSeparator)
+		}
+		sb.WriteString(strconv.Itoa(int(number)))
+	}
+	return sb.String()
+}
@@ -0,0 +1,151 @@
+package gpb
+
+import (
+	"testing"
+
+	"github.com/ywx217/gpb/protoscope"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// malformedNested returns a message with field 1 = 1, and field 4 holding a message whose
+// string field 1 is truncated at offset 4.
+func malformedNested() []byte {
+	inner := []byte{0x0a, 0x05, 'a', 'b'}
+	pb := protowire.AppendTag(nil, 1, protowire.VarintType)
+	pb = protowire.AppendVarint(pb, 1)
+	pb = protowire.AppendTag(pb, 4, protowire.BytesType)
+	return protowire.AppendBytes(pb, inner)
+}
+
+func requireParseError(t *testing.T, err error) *ParseError {
+	var pe *ParseError
+	require.True(t, errors.As(err, &pe), "%v is not a ParseError", err)
+	return pe
+}
+
+func TestParseErrorNested(t *testing.T) {
+	pb := malformedNested()
+	expected := &ParseError{
+		Offset:   4,
+		Path:     []protowire.Number{4},
+		Number:   1,
+		WireType: protowire.BytesType,
+		Err:      ErrInvalidLength,
+	}
+
+	_, err := GetOneE(pb, 4, 1)
+	require.ErrorIs(t, err, ErrInvalidLength)
+	pe := requireParseError(t, err)
+	require.Equal(t, expected.Offset, pe.Offset)
+	require.Equal(t, expected.Path, pe.Path)
+	require.Equal(t, expected.Number, pe.Number)
+	require.Equal(t, expected.WireType, pe.WireType)
+	require.Equal(t, "offset=4 path=4 field=1 wire_type=2: invalid length", err.Error())
+	require.Equal(t, ErrInvalidLength, errors.Cause(err))
+
+	err = ForEachPath(pb, "4.1", func(Result) bool { return true })
+	require.Equal(t, expected.Error(), err.Error())
+	err = MustCompileMany("1", "4.1").GetMany(pb, make([]Result, 2))
+	require.Equal(t, expected.Error(), err.Error())
+
+	// errors below wildcards are ignored
+	require.NoError(t, ForEachPath(pb, "*.1", func(Result) bool { return true }))
+
+	// offsets are absolute in the root buffer as the offsets of the result are, the path is
+	// relative to the result
+	_, err = GetOne(pb, 4).GetOneE(1)
+	pe = requireParseError(t, err)
+	require.Equal(t, 4, pe.Offset)
+	require.Empty(t, pe.Path)
+	_, err = GetOne(pb, 4).GetAllE(1)
+	require.Equal(t, 4, requireParseError(t, err).Offset)
+	err = GetOne(pb, 4).ForEachPath("1", func(Result) bool { return true })
+	require.Equal(t, 4, requireParseError(t, err).Offset)
+
+	// offsets are relative to Raw for the results built by callers
+	_, err = Result{Raw: GetOne(pb, 4).Raw}.GetOneE(1)
+	require.Equal(t, 0, requireParseError(t, err).Offset)
+}
+
+func TestParseErrorGroup(t *testing.T) {
+	pb := protowire.AppendTag(nil, 1, protowire.VarintType)
+	pb = protowire.AppendVarint(pb, 1)
+	pb = protowire.AppendTag(pb, 3, protowire.StartGroupType)
+	pb = protowire.AppendTag(pb, 5, protowire.StartGroupType)
+	pb = protowire.AppendTag(pb, 6, protowire.VarintType)
+	pb = append(pb, 0x80)
+
+	_, err := GetAllE(pb, 1)
+	pe := requireParseError(t, err)
+	require.Equal(t, 4, pe.Offset)
+	require.Equal(t, []protowire.Number{3, 5}, pe.Path)
+	require.Equal(t, protowire.Number(6), pe.Number)
+	require.Equal(t, protowire.VarintType, pe.WireType)
+	require.ErrorIs(t, err, ErrInvalidLength)
+
+	// the end group tag is missing
+	pb = protowire.AppendTag(pb[:4], 6, protowire.VarintType)
+	pb = protowire.AppendVarint(pb, 1)
+	pb = protowire.AppendTag(pb, 5, protowire.EndGroupType)
+	_, err = GetAllE(pb, 1)
+	pe = requireParseError(t, err)
+	require.Equal(t, 2, pe.Offset)
+	require.Empty(t, pe.Path)
+	require.Equal(t, protowire.Number(3), pe.Number)
+	require.Equal(t, protowire.StartGroupType, pe.WireType)
+	require.ErrorIs(t, err, ErrEndGroupNotFound)
+}
+
+func TestParseErrorTag(t *testing.T) {
+	pb := []byte{0x08, 0x01, 0x80}
+	_, err := GetAllE(pb, 1)
+	pe := requireParseError(t, err)
+	require.Equal(t, 2, pe.Offset)
+	require.Equal(t, protowire.Number(0), pe.Number)
+	require.Equal(t, InvalidWireType, pe.WireType)
+	require.Equal(t, "offset=2: invalid length", err.Error())
+
+	pb = []byte{0x08, 0x01, 0x0e}
+	_, err = GetAllE(pb, 1)
+	require.ErrorIs(t, err, ErrUnknownWireType)
+	require.Equal(t, "offset=2 field=1 wire_type=6: wire_type=6: unknown wire type", err.Error())
+}
+
+func TestParseErrorHexdump(t *testing.T) {
+	pb := malformedNested()
+	_, err := GetOneE(pb, 4, 1)
+	pe := requireParseError(t, err)
+	require.Equal(t, ""+
+		"00000000  08 01 22 04 0a 05 61 62                           |..\"...ab        |\n"+
+		"                      ^^\n",
+		pe.Hexdump(pb, 16))
+
+	long := make([]byte, 40)
+	pe = &ParseError{Offset: 22, Err: ErrInvalidLength}
+	require.Equal(t, ""+
+		"00000010              00 00 00 00  00                       |    .....       |\n"+
+		"                            ^^\n",
+		pe.Hexdump(long, 2))
+
+	pe = &ParseError{Offset: 8, Err: ErrInvalidLength}
+	require.Equal(t, ""+
+		"00000000                    61 62                           |      ab        |\n"+
+		"00000008  <end of buffer>\n",
+		pe.Hexdump(pb, 2))
+}
+
+func TestParseErrorFixtures(t *testing.T) {
+	for text, expected := range map[string]string{
+		"1: 1 4: {`0a05` \"ab\"}":                  "offset=4 path=4 field=1 wire_type=2: invalid length",
+		"1: 1 4: {1: 2 3: !{ 5:6 }}":               "offset=7 path=4.3 field=5 wire_type=6: wire_type=6: unknown wire type",
+		"1: 1 4: {3: !{ 5: 1 }} 4: {1:SGROUP}":     "offset=10 path=4 field=1 wire_type=3: end group not found",
+		"1: 1 4: {3: !{ 5: 1 6:EGROUP 3:EGROUP }}": "offset=7 path=4.3 field=6 wire_type=4: mismatched end group",
+	} {
+		pb := protoscope.MustParse(text)
+		_, err := GetAllE(pb, 4, 1)
+		require.EqualError(t, err, expected, protoscope.Format(pb))
+	}
+}
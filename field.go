@@ -0,0 +1,190 @@
+package gpb
+
+import (
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// Field is a typed handle of a field, the kind of which is fixed once at declaration, e.g.
+//
+//	var sint32Required = gpb.Sint32Field(102)
+//
+//	v, ok := sint32Required.Get(pb)
+//
+// The values are decoded by the accessor of the kind, e.g. Result.Sint32 for sint32 fields, and
+// the occurrences of other wire types are skipped instead of being decoded into wrong values.
+// Like GetRepeated, the length-delimited occurrences of scalar kinds are read as packed frames.
+// A Field is immutable and safe to be shared across goroutines.
+type Field[T any] struct {
+	path     []protowire.Number
+	kind     protoreflect.Kind
+	itemType protowire.Type
+	decode   func(Result) T
+}
+
+func newField[T any](kind protoreflect.Kind, decode func(Result) T, pbNumbers []protowire.Number) Field[T] {
+	return Field[T]{
+		path:     append([]protowire.Number{}, pbNumbers...),
+		kind:     kind,
+		itemType: wireTypeOf(kind),
+		decode:   decode,
+	}
+}
+
+func Int32Field(pbNumbers ...protowire.Number) Field[int32] {
+	return newField(protoreflect.Int32Kind, Result.Int32, pbNumbers)
+}
+
+func Int64Field(pbNumbers ...protowire.Number) Field[int64] {
+	return newField(protoreflect.Int64Kind, Result.Int64, pbNumbers)
+}
+
+func Uint32Field(pbNumbers ...protowire.Number) Field[uint32] {
+	return newField(protoreflect.Uint32Kind, Result.Uint32, pbNumbers)
+}
+
+func Uint64Field(pbNumbers ...protowire.Number) Field[uint64] {
+	return newField(protoreflect.Uint64Kind, Result.Uint64, pbNumbers)
+}
+
+func Sint32Field(pbNumbers ...protowire.Number) Field[int32] {
+	return newField(protoreflect.Sint32Kind, Result.Sint32, pbNumbers)
+}
+
+func Sint64Field(pbNumbers ...protowire.Number) Field[int64] {
+	return newField(protoreflect.Sint64Kind, Result.Sint64, pbNumbers)
+}
+
+func BoolField(pbNumbers ...protowire.Number) Field[bool] {
+	return newField(protoreflect.BoolKind, Result.Bool, pbNumbers)
+}
+
+// EnumField reads the enum values as numbers, use them with the generated enum types, e.g.
+// `testprotos.GoTest_KIND(v)`.
+func EnumField(pbNumbers ...protowire.Number) Field[protoreflect.EnumNumber] {
+	return newField(protoreflect.EnumKind, func(r Result) protoreflect.EnumNumber {
+		return protoreflect.EnumNumber(r.Int32())
+	}, pbNumbers)
+}
+
+func Fixed32Field(pbNumbers ...protowire.Number) Field[uint32] {
+	return newField(protoreflect.Fixed32Kind, Result.Fixed32, pbNumbers)
+}
+
+func SFixed32Field(pbNumbers ...protowire.Number) Field[int32] {
+	return newField(protoreflect.Sfixed32Kind, Result.SFixed32, pbNumbers)
+}
+
+func Float32Field(pbNumbers ...protowire.Number) Field[float32] {
+	return newField(protoreflect.FloatKind, Result.Float32, pbNumbers)
+}
+
+func Fixed64Field(pbNumbers ...protowire.Number) Field[uint64] {
+	return newField(protoreflect.Fixed64Kind, Result.Fixed64, pbNumbers)
+}
+
+func SFixed64Field(pbNumbers ...protowire.Number) Field[int64] {
+	return newField(protoreflect.Sfixed64Kind, Result.SFixed64, pbNumbers)
+}
+
+func Float64Field(pbNumbers ...protowire.Number) Field[float64] {
+	return newField(protoreflect.DoubleKind, Result.Float64, pbNumbers)
+}
+
+func StringField(pbNumbers ...protowire.Number) Field[string] {
+	return newField(protoreflect.StringKind, Result.String, pbNumbers)
+}
+
+// BytesField reads the bytes sliced from the message, copy them before the message is modified.
+func BytesField(pbNumbers ...protowire.Number) Field[[]byte] {
+	return newField(protoreflect.BytesKind, Result.Bytes, pbNumbers)
+}
+
+// MessageField reads the embedded messages as results, so their fields can be read further.
+func MessageField(pbNumbers ...protowire.Number) Field[Result] {
+	return newField(protoreflect.MessageKind, func(r Result) Result { return r }, pbNumbers)
+}
+
+// GroupField reads the groups as results, so their fields can be read further.
+func GroupField(pbNumbers ...protowire.Number) Field[Result] {
+	return newField(protoreflect.GroupKind, func(r Result) Result { return r }, pbNumbers)
+}
+
+// Kind returns the kind of the field.
+func (f Field[T]) Kind() protoreflect.Kind {
+	return f.kind
+}
+
+// Path returns the field numbers of the field.
+func (f Field[T]) Path() []protowire.Number {
+	return append([]protowire.Number{}, f.path...)
+}
+
+// Get gets the first value of the field like GetOne, false is returned if it is missing or the
+// message is malformed.
+func (f Field[T]) Get(pb []byte) (T, bool) {
+	var value T
+	var found bool
+	_ = f.Iter(pb, func(v T) bool {
+		value, found = v, true
+		return false
+	})
+	return value, found
+}
+
+// All gets all the values of the field in wire order.
+func (f Field[T]) All(pb []byte) []T {
+	values := make([]T, 0)
+	_ = f.Iter(pb, func(v T) bool {
+		values = append(values, v)
+		return true
+	})
+	return values
+}
+
+// Iter calls fn with the values of the field in wire order until fn returns false, the error of
+// a malformed message is returned.
+func (f Field[T]) Iter(pb []byte, fn func(v T) bool) error {
+	var stop bool
+	return Result{Raw: pb}.GetIter(func(field Result) bool {
+		iterElements(field, f.itemType, func(item Result) bool {
+			stop = !fn(f.decode(item))
+			return !stop
+		})
+		return !stop
+	}, f.path...)
+}
+
+// iterElements calls fn with the elements of the field like appendElements, without any
+// allocation. Malformed bytes at the end of packed frames are ignored.
+func iterElements(field Result, itemType protowire.Type, fn func(Result) bool) {
+	switch {
+	case field.WireType == itemType:
+		fn(field)
+		return
+	case field.WireType != protowire.BytesType:
+		return
+	}
+	item := Result{WireType: itemType, Number: field.Number}
+	for pb := field.Raw; len(pb) > 0; {
+		n := -1
+		switch itemType {
+		case protowire.VarintType:
+			item.Varint, n = protowire.ConsumeVarint(pb)
+		case protowire.Fixed32Type:
+			_, n = protowire.ConsumeFixed32(pb)
+		case protowire.Fixed64Type:
+			_, n = protowire.ConsumeFixed64(pb)
+		}
+		if n < 0 {
+			return
+		}
+		item.Raw = pb[:n]
+		item.Start = field.itemStart(pb)
+		item.End = item.Start + n
+		if !fn(item) {
+			return
+		}
+		pb = pb[n:]
+	}
+}
@@ -0,0 +1,105 @@
+package gpb
+
+import (
+	"testing"
+
+	"github.com/ywx217/gpb/internal/testprotos"
+	"github.com/ywx217/gpb/protoscope"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+func requireGet[T any](t *testing.T, expected T, f Field[T], pb []byte) {
+	t.Helper()
+	v, ok := f.Get(pb)
+	require.True(t, ok, "field %v", f.Path())
+	require.Equal(t, expected, v, "field %v", f.Path())
+}
+
+func TestField(t *testing.T) {
+	msg := initGoTest(true)
+	bs, err := proto.Marshal(msg)
+	require.NoError(t, err)
+
+	requireGet(t, protoreflect.EnumNumber(testprotos.GoTest_TIME), EnumField(1), bs)
+	requireGet(t, true, BoolField(10), bs)
+	requireGet(t, msg.GetF_Int32Required(), Int32Field(11), bs)
+	requireGet(t, msg.GetF_Int64Required(), Int64Field(12), bs)
+	requireGet(t, msg.GetF_Fixed32Required(), Fixed32Field(13), bs)
+	requireGet(t, msg.GetF_Fixed64Required(), Fixed64Field(14), bs)
+	requireGet(t, msg.GetF_Uint32Required(), Uint32Field(15), bs)
+	requireGet(t, msg.GetF_Uint64Required(), Uint64Field(16), bs)
+	requireGet(t, msg.GetF_FloatRequired(), Float32Field(17), bs)
+	requireGet(t, msg.GetF_DoubleRequired(), Float64Field(18), bs)
+	requireGet(t, msg.GetF_StringRequired(), StringField(19), bs)
+	requireGet(t, msg.GetF_BytesRequired(), BytesField(101), bs)
+	requireGet(t, msg.GetF_Sint32Required(), Sint32Field(102), bs)
+	requireGet(t, msg.GetF_Sint64Required(), Sint64Field(103), bs)
+	requireGet(t, msg.GetF_Sfixed32Required(), SFixed32Field(104), bs)
+	requireGet(t, msg.GetF_Sfixed64Required(), SFixed64Field(105), bs)
+	requireGet(t, "label", StringField(4, 1), bs)
+
+	requiredField, ok := MessageField(4).Get(bs)
+	require.True(t, ok)
+	require.Equal(t, "type", requiredField.GetOne(2).String())
+	group, ok := GroupField(70).Get(bs)
+	require.True(t, ok)
+	require.Equal(t, "required", group.GetOne(71).String())
+
+	// the wrong kinds are missing
+	_, ok = Fixed32Field(102).Get(bs)
+	require.False(t, ok)
+	_, ok = StringField(102).Get(bs)
+	require.False(t, ok)
+	_, ok = MessageField(70).Get(bs)
+	require.False(t, ok)
+	_, ok = Int32Field(3).Get(bs)
+	require.False(t, ok)
+
+	require.Equal(t, protoreflect.Sint32Kind, Sint32Field(102).Kind())
+	require.Equal(t, []protowire.Number{4, 1}, StringField(4, 1).Path())
+}
+
+func TestFieldRepeated(t *testing.T) {
+	msg := initGoTest(false)
+	msg.F_Sint32RepeatedPacked = []int32{-1, 2, -3}
+	bs, err := proto.Marshal(msg)
+	require.NoError(t, err)
+	require.Equal(t, []int32{-1, 2, -3}, Sint32Field(502).All(bs))
+	requireGet(t, int32(-1), Sint32Field(502), bs)
+}
+
+func TestFieldMixed(t *testing.T) {
+	pb := protoscope.MustParse(`1: 1i32 1: {2i32 3i32} 1: 4 2: {1: {"a"}} 2: {1: {"b"}}`)
+	require.Equal(t, []uint32{1, 2, 3}, Fixed32Field(1).All(pb))
+	require.Equal(t, []string{"a", "b"}, StringField(2, 1).All(pb))
+	require.Empty(t, Int64Field(3).All(pb))
+
+	var values []uint32
+	require.NoError(t, Fixed32Field(1).Iter(pb, func(v uint32) bool {
+		values = append(values, v)
+		return v < 2
+	}))
+	require.Equal(t, []uint32{1, 2}, values)
+
+	// malformed messages
+	pb = protoscope.MustParse(`1: 1 ` + "`0a05`")
+	err := Uint64Field(1).Iter(pb, func(v uint64) bool { return true })
+	var pe *ParseError
+	require.ErrorAs(t, err, &pe)
+	requireGet(t, uint64(1), Uint64Field(1), pb)
+
+	// no allocation
+	pb = protoscope.MustParse(`1: {1 2 3}`)
+	f := Uint64Field(1)
+	var sum uint64
+	require.Zero(t, testing.AllocsPerRun(100, func() {
+		_ = f.Iter(pb, func(v uint64) bool {
+			sum += v
+			return true
+		})
+	}))
+}
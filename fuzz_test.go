@@ -0,0 +1,184 @@
+package gpb
+
+import (
+	"math"
+	"testing"
+
+	"github.com/ywx217/gpb/internal/testprotos"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+var partialUnmarshal = proto.UnmarshalOptions{AllowPartial: true}
+
+// fuzzSeeds adds the encoded messages of internal/testprotos and some hostile payloads to the
+// seed corpus.
+func fuzzSeeds(f *testing.F) {
+	full := initGoTest(true)
+	full.Table = proto.String("table")
+	full.Param = proto.Int32(-1)
+	full.RepeatedField = []*testprotos.GoTestField{initGoTestField(), initGoTestField()}
+	full.F_Int32Repeated = []int32{1, -1, 1 << 20}
+	full.F_Int32RepeatedPacked = []int32{1, -1, 1 << 20}
+	full.F_Fixed32RepeatedPacked = []uint32{1, 1 << 31}
+	full.F_Fixed64RepeatedPacked = []uint64{1, 1 << 63}
+	full.Repeatedgroup = []*testprotos.GoTest_RepeatedGroup{initGoTestRepeatedGroup()}
+	full.Optionalgroup = initGoTestOptionalGroup()
+	for _, msg := range []proto.Message{
+		initGoTest(false),
+		full,
+		&testprotos.PackedTest{B: []int32{1, 2, 3}},
+		&testprotos.MaxTag{LastField: proto.String("last")},
+	} {
+		bs, err := proto.Marshal(msg)
+		require.NoError(f, err)
+		f.Add(bs)
+	}
+	f.Add([]byte{0x0a, 0xff, 0xff, 0xff, 0xff, 0x0f}) // length overflows the buffer
+	f.Add([]byte{0x0a, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x01})
+	f.Add([]byte{0x0b, 0x0b, 0x0b, 0x0c}) // groups without end
+	f.Add([]byte{0x0e})                   // unknown wire type
+	f.Add([]byte{0x80})                   // truncated tag
+}
+
+// lastField returns the last value of the leaf type along the path of messages, the fields of
+// other wire types are skipped as unknown fields by proto.Unmarshal.
+func lastField(pb []byte, leafType protowire.Type, numbers ...protowire.Number) (result Result) {
+	result.WireType = InvalidWireType
+	parentType := protowire.BytesType
+	if numbers[0] >= 70 {
+		// RequiredGroup, RepeatedGroup and OptionalGroup
+		parentType = protowire.StartGroupType
+	}
+	_, _ = Result{Raw: pb}.IterFields(numbers[0], func(r Result) bool {
+		if len(numbers) == 1 && r.WireType == leafType {
+			result = r
+		} else if len(numbers) > 1 && r.WireType == parentType {
+			if last := lastField(r.Raw, leafType, numbers[1:]...); last.Exist() {
+				result = last
+			}
+		}
+		return true
+	})
+	return
+}
+
+func FuzzGetOne(f *testing.F) {
+	fuzzSeeds(f)
+	f.Fuzz(func(t *testing.T, pb []byte) {
+		_ = GetOne(pb, 4, 1)
+		_ = GetLast(pb, 70, 71)
+		_, _ = GetAllE(pb, 5, 2)
+		_ = Get(pb, "*.*.1")
+		_ = Get(pb, "5.#last.1")
+		_ = GetMany(pb, "1", "4.1", "5.#", "*.2")
+
+		msg := &testprotos.GoTest{}
+		if partialUnmarshal.Unmarshal(pb, msg) != nil {
+			return
+		}
+		// well-formed messages are parsed without errors into the same values
+		for _, numbers := range [][]protowire.Number{{3}, {4, 1}, {5, 2}, {70, 71}} {
+			_, err := GetAllE(pb, numbers...)
+			require.NoError(t, err)
+		}
+		require.Equal(t, msg.GetParam(), lastField(pb, protowire.VarintType, 3).Int32())
+		require.Equal(t, msg.GetTable(), lastField(pb, protowire.BytesType, 2).String())
+		require.Equal(t, msg.GetF_Fixed32Required(), lastField(pb, protowire.Fixed32Type, 13).Fixed32())
+		require.Equal(t, math.Float64bits(msg.GetF_DoubleRequired()), lastField(pb, protowire.Fixed64Type, 18).Fixed64())
+		require.Equal(t, msg.GetF_Sint32Required(), lastField(pb, protowire.VarintType, 102).Sint32())
+		require.Equal(t, msg.GetRequiredField().GetLabel(), lastField(pb, protowire.BytesType, 4, 1).String())
+		require.Equal(t, msg.GetRequiredgroup().GetRequiredField(), lastField(pb, protowire.BytesType, 70, 71).String())
+	})
+}
+
+func FuzzIterFields(f *testing.F) {
+	fuzzSeeds(f)
+	f.Fuzz(func(t *testing.T, pb []byte) {
+		var fields int
+		n, err := Result{Raw: pb}.IterFields(1, func(Result) bool {
+			fields++
+			return true
+		})
+		require.LessOrEqual(t, n, len(pb))
+		if err != nil {
+			var pe *ParseError
+			require.True(t, errors.As(err, &pe))
+			require.GreaterOrEqual(t, pe.Offset, n)
+			require.LessOrEqual(t, pe.Offset, len(pb))
+		} else {
+			require.Equal(t, len(pb), n)
+		}
+		if proto.Unmarshal(pb, &emptypb.Empty{}) == nil {
+			require.NoError(t, err)
+		}
+	})
+}
+
+// packedGoTest unpacks the payload of the packed field with proto.Unmarshal, false is returned
+// if the payload is rejected.
+func packedGoTest(pb []byte, number protowire.Number) (*testprotos.GoTest, bool) {
+	bs := protowire.AppendTag(nil, number, protowire.BytesType)
+	bs = protowire.AppendBytes(bs, pb)
+	msg := &testprotos.GoTest{}
+	return msg, partialUnmarshal.Unmarshal(bs, msg) == nil
+}
+
+// requireUnpacked checks that the items are decoded from the whole of pb if any.
+func requireUnpacked(t *testing.T, pb []byte, wireType protowire.Type, items []Result) {
+	var length int
+	for _, item := range items {
+		require.Equal(t, wireType, item.WireType)
+		length += len(item.Raw)
+	}
+	require.LessOrEqual(t, length, len(pb))
+}
+
+func FuzzUnpackVarint(f *testing.F) {
+	f.Add([]byte{0x01, 0xff, 0x01, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x01})
+	f.Add([]byte{0x80})
+	f.Fuzz(func(t *testing.T, pb []byte) {
+		items := Result{WireType: protowire.BytesType, Raw: pb}.UnpackVarint()
+		requireUnpacked(t, pb, protowire.VarintType, items)
+		if msg, ok := packedGoTest(pb, 51); ok {
+			require.Len(t, items, len(msg.F_Int32RepeatedPacked))
+			for i, v := range msg.F_Int32RepeatedPacked {
+				require.Equal(t, v, items[i].Int32())
+			}
+		}
+	})
+}
+
+func FuzzUnpackFixed32(f *testing.F) {
+	f.Add([]byte{0x01, 0x00, 0x00, 0x80, 0xff, 0xff, 0xff, 0xff})
+	f.Add([]byte{0x01, 0x02, 0x03})
+	f.Fuzz(func(t *testing.T, pb []byte) {
+		items := Result{WireType: protowire.BytesType, Raw: pb}.UnpackFixed32()
+		requireUnpacked(t, pb, protowire.Fixed32Type, items)
+		if msg, ok := packedGoTest(pb, 53); ok {
+			require.Len(t, items, len(msg.F_Fixed32RepeatedPacked))
+			for i, v := range msg.F_Fixed32RepeatedPacked {
+				require.Equal(t, v, items[i].Fixed32())
+			}
+		}
+	})
+}
+
+func FuzzUnpackFixed64(f *testing.F) {
+	f.Add([]byte{0x01, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x80})
+	f.Add([]byte{0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07})
+	f.Fuzz(func(t *testing.T, pb []byte) {
+		items := Result{WireType: protowire.BytesType, Raw: pb}.UnpackFixed64()
+		requireUnpacked(t, pb, protowire.Fixed64Type, items)
+		if msg, ok := packedGoTest(pb, 54); ok {
+			require.Len(t, items, len(msg.F_Fixed64RepeatedPacked))
+			for i, v := range msg.F_Fixed64RepeatedPacked {
+				require.Equal(t, v, items[i].Fixed64())
+			}
+		}
+	})
+}
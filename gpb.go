@@ -16,8 +16,21 @@ var (
 	ErrUnknownWireType  = errors.New("unknown wire type")
 	ErrInvalidLength    = errors.New("invalid length")
 	ErrEndGroupNotFound = errors.New("end group not found")
+	ErrEndGroupMismatch = errors.New("mismatched end group")
+	ErrInvalidPath      = errors.New("invalid path")
+	ErrLimitExceeded    = errors.New("limit exceeded")
+	ErrNotInPlace       = errors.New("can not be set in place")
+	ErrUnbalanced       = errors.New("unbalanced message")
+	ErrTypeMismatch     = errors.New("wire type mismatch")
+	ErrNotFound         = errors.New("field not found")
+	ErrOutOfRange       = errors.New("value out of range")
+	ErrInvalidUTF8      = errors.New("invalid UTF-8")
 )
 
+// maxGroupDepth groups nested deeper than this are rejected by default, so that hostile input can
+// not exhaust the stack.
+const maxGroupDepth = protowire.DefaultRecursionLimit
+
 const InvalidWireType protowire.Type = -1
 
 type Result struct {
@@ -27,9 +40,16 @@ type Result struct {
 	//   type==5, used for: fixed32, sfixed32, float
 	//   type==2, used for: string, bytes, embedded messages, packed repeated fields
 	WireType protowire.Type
+	// Number the field number, 0 for the results not read from a field, e.g. the count of a path
+	Number protowire.Number
 
 	Varint uint64
 	Raw    []byte // message without length header
+
+	// Start, End the offsets of the field in the root buffer given to the API, from the head of
+	// the tag to the end of the value, including the length prefix and the end group tag. The
+	// offsets of unpacked items cover the values only.
+	Start, End int
 }
 
 // GetOne gets the first value by the given field numbers. `pbNumbers` indicates
@@ -37,7 +57,7 @@ type Result struct {
 // **Attention**: when the field is repeated in proto2, the first item is returned.
 //   In proto3 or proto2 packed mode, the first packed group is returned, and
 //   the UnpackVarint / UnpackFixed32 / UnpackFixed64 should be called to break
-//   a single length-delimited frame into multiple Results.
+//   a single length-delimited frame into multiple Results, or GetRepeated is used instead.
 func GetOne(pb []byte, pbNumbers ...protowire.Number) Result {
 	state := Result{Raw: pb}
 	return state.GetOne(pbNumbers...)
@@ -50,6 +70,27 @@ func GetAll(pb []byte, pbNumbers ...protowire.Number) []Result {
 	return state.GetAll(pbNumbers...)
 }
 
+// GetOneE like GetOne, but the error of a malformed message is returned instead of being
+// discarded, so a truncated or corrupted payload can be told apart from a missing field.
+func GetOneE(pb []byte, pbNumbers ...protowire.Number) (Result, error) {
+	state := Result{Raw: pb}
+	return state.GetOneE(pbNumbers...)
+}
+
+// GetAllE like GetAll, but the error of a malformed message is returned instead of being
+// discarded.
+func GetAllE(pb []byte, pbNumbers ...protowire.Number) ([]Result, error) {
+	state := Result{Raw: pb}
+	return state.GetAllE(pbNumbers...)
+}
+
+// GetLast gets the last value by the given field numbers, which follows the protobuf
+// specification for non-repeated fields. See Result.GetLast for details.
+func GetLast(pb []byte, pbNumbers ...protowire.Number) Result {
+	state := Result{Raw: pb}
+	return state.GetLast(pbNumbers...)
+}
+
 // GetOne gets the first field by the given field numbers. `pbNumbers` indicates
 // the path to retrieve the desired field. There is no heap-memory allocation in this
 // function.
@@ -57,164 +98,533 @@ func GetAll(pb []byte, pbNumbers ...protowire.Number) []Result {
 // **Attention**: when the field is repeated in proto2, the first item is returned.
 //   In proto3 or proto2 packed mode, the first packed group is returned, and
 //   the UnpackVarint / UnpackFixed32 / UnpackFixed64 should be called to break
-//   a single length-delimited frame into multiple Results.
-func (r Result) GetOne(pbNumbers ...protowire.Number) (result Result) {
+//   a single length-delimited frame into multiple Results, or GetRepeated is used instead.
+func (r Result) GetOne(pbNumbers ...protowire.Number) Result {
+	if len(pbNumbers) == 1 && pbNumbers[0] != anyNumber {
+		result, _ := r.firstNumber(pbNumbers[0])
+		return result
+	}
+	result, _ := r.GetOneE(pbNumbers...)
+	return result
+}
+
+// GetOneE like GetOne, but the error of a malformed message is returned. A Result with
+// InvalidWireType is returned along with the error.
+//
+// **Attention**: the iteration stops at the first value found, so only the bytes before it are
+//   validated, use GetAllE to validate the whole message.
+func (r Result) GetOneE(pbNumbers ...protowire.Number) (result Result, err error) {
+	if len(pbNumbers) == 1 && pbNumbers[0] != anyNumber {
+		return r.firstNumber(pbNumbers[0])
+	}
 	result.WireType = InvalidWireType
 	// use callback to avoid heap memory allocation
-	_ = r.GetIter(func(r Result) bool {
+	err = r.GetIter(func(r Result) bool {
 		result = r
 		return false
 	}, pbNumbers...)
+	if err != nil {
+		result = Result{WireType: InvalidWireType}
+	}
+	return
+}
+
+// GetLast unlike GetOne, GetLast gets the last field by the given field numbers. There is no
+// heap-memory allocation in this function.
+//
+// According to the protobuf specification, the last value wins when a non-repeated scalar
+// appears multiple times, and multiple occurrences of an embedded message are merged. All the
+// occurrences of each field in the path are walked through in order, so the intermediate
+// messages are logically merged, and the result is the same as what generated code sees after
+// unmarshalling concatenated payloads.
+//
+// **Attention**: when the desired field itself is an embedded message, only its last
+//   occurrence is returned, use GetAll to get all the occurrences to be merged.
+func (r Result) GetLast(pbNumbers ...protowire.Number) (result Result) {
+	result.WireType = InvalidWireType
+	_ = r.GetIter(func(r Result) bool {
+		result = r
+		return true
+	}, pbNumbers...)
 	return
 }
 
 // GetAll unlike GetOne, GetAll returns all the values by the given field numbers.
 func (r Result) GetAll(pbNumbers ...protowire.Number) []Result {
+	results, _ := r.GetAllE(pbNumbers...)
+	return results
+}
+
+// GetAllE like GetAll, but the error of a malformed message is returned, along with the values
+// found before the error occurred.
+func (r Result) GetAllE(pbNumbers ...protowire.Number) ([]Result, error) {
 	results := make([]Result, 0)
-	_ = r.GetIter(func(r Result) bool {
+	err := r.GetIter(func(r Result) bool {
 		results = append(results, r)
 		return true
 	}, pbNumbers...)
-	return results
+	return results, err
 }
 
 // GetIter like GetAll, gets all the values until the resultSink returns false.
-// Both GetOne and GetAll are implemented by this function.
-func (r Result) GetIter(resultSink func(Result) bool, pbNumbers ...protowire.Number) (err error) {
-	// use recursion calls to iterate through the data in depth first order.
-	// according to the BenchmarkOptimized, dfs has a better performance than bfs.
-	var skip bool
-	var depth int
-	var walkFunc func(Result) bool
-	walkFunc = func(it Result) bool {
-		if skip {
-			return false
-		}
-		if depth == len(pbNumbers)-1 {
-			if !resultSink(it) {
-				skip = true
-				return false
+// Both GetOne and GetAll are implemented by this function. Only length-delimited fields
+// and groups are descended into, scalars in the middle of the path are skipped.
+func (r Result) GetIter(resultSink func(Result) bool, pbNumbers ...protowire.Number) error {
+	return r.getIter(nil, resultSink, pbNumbers...)
+}
+
+// getIter is GetIter with the limits checked, the limiter may be nil for no limits.
+func (r Result) getIter(l *limiter, resultSink func(Result) bool, pbNumbers ...protowire.Number) error {
+	if len(pbNumbers) == 0 {
+		// nothing to match
+		return nil
+	}
+	if len(pbNumbers) == 1 && pbNumbers[0] != anyNumber && l == nil {
+		// the fast path of the fields in r, which need no walker
+		_, err := r.iterNumber(pbNumbers[0], func(field Result) bool {
+			// a stray end group tag is not a value
+			return field.WireType == protowire.EndGroupType || resultSink(field)
+		})
+		return err
+	}
+	w := iterWalker{numbers: pbNumbers, sink: resultSink, limits: l}
+	return locate(w.walk(r, 0), r)
+}
+
+// iterWalker walks through the messages in depth first order, which is shared by GetIter and the
+// path queries. According to the BenchmarkOptimized, dfs has a better performance than bfs.
+type iterWalker struct {
+	// numbers the field numbers of GetIter, segments are used instead if it is nil
+	numbers  []protowire.Number
+	segments []pathSegment
+	sink     func(Result) bool
+	limits   *limiter
+	stopped  bool
+}
+
+// segment returns the segment at depth, the field numbers are segments selecting all the
+// occurrences.
+func (w *iterWalker) segment(depth int) pathSegment {
+	if w.numbers != nil {
+		return pathSegment{number: w.numbers[depth]}
+	}
+	return w.segments[depth]
+}
+
+func (w *iterWalker) depth() int {
+	if w.numbers != nil {
+		return len(w.numbers)
+	}
+	return len(w.segments)
+}
+
+// walk iterates through the fields in msg matched by the segment at depth.
+func (w *iterWalker) walk(msg Result, depth int) error {
+	seg := w.segment(depth)
+	var err error
+	if seg.selector == selectAll {
+		// the fast path of GetIter, all the occurrences are visited
+		_, iterErr := msg.iterFields(w.limits, depth, seg.number, func(field Result) bool {
+			if field.WireType == protowire.EndGroupType {
+				// a stray end group tag is not a value
+				return true
 			}
+			err = w.visit(field, depth)
+			return err == nil && !w.stopped
+		})
+		if iterErr != nil {
+			return iterErr
+		}
+		return err
+	}
+	var last Result
+	var occurrence int
+	_, iterErr := msg.iterFields(w.limits, depth, seg.number, func(field Result) bool {
+		if field.WireType == protowire.EndGroupType {
+			// a stray end group tag is not a value
 			return true
 		}
-		// recursion call
-		depth++
-		if _, innerErr := it.IterFields(pbNumbers[depth], walkFunc); innerErr != nil {
-			err = innerErr
-			skip = true
+		index := occurrence
+		occurrence++
+		switch seg.selector {
+		case selectIndex:
+			if index < seg.index {
+				return true
+			}
+			// the selected occurrence is found, no need to read the following fields
+			err = w.visit(field, depth)
 			return false
+		case selectLast:
+			// the last occurrence is unknown until all the fields are read
+			last = field
+			return true
+		case selectRange:
+			if index < seg.index {
+				return true
+			}
+			if seg.end >= 0 && index >= seg.end {
+				return false
+			}
 		}
-		depth--
-		return true
+		err = w.visit(field, depth)
+		return err == nil && !w.stopped
+	})
+	if iterErr != nil {
+		return iterErr
+	} else if err != nil {
+		return err
 	}
-	if _, innerErr := r.IterFields(pbNumbers[depth], walkFunc); innerErr != nil {
-		err = innerErr
+	if seg.selector == selectLast && occurrence > 0 {
+		return w.visit(last, depth)
 	}
-	return
+	return nil
+}
+
+// visit feeds the field to the sink at the end of the path, or descends into it otherwise. Only
+// length-delimited fields and groups are descended into, scalars in the middle of the path are
+// skipped.
+func (w *iterWalker) visit(field Result, depth int) error {
+	if depth == w.depth()-1 {
+		if !w.sink(field) {
+			w.stopped = true
+		}
+		return nil
+	}
+	if field.WireType != protowire.BytesType && field.WireType != protowire.StartGroupType {
+		// scalars have no fields inside
+		return nil
+	}
+	err := w.walk(field, depth+1)
+	if w.segment(depth).number == anyNumber && ignorable(err) {
+		// length-delimited fields matched by a wildcard are not necessarily messages
+		return nil
+	}
+	return enclose(err, field.Number)
+}
+
+// ForEach iterates through all the fields in pb in order regardless of the field numbers, until
+// the iterator returns false. See Result.ForEach for details.
+func ForEach(pb []byte, iterator func(num protowire.Number, f Result) bool) error {
+	return Result{Raw: pb}.ForEach(iterator)
+}
+
+// ForEach iterates through all the fields in r.Raw in order regardless of the field numbers, until
+// the iterator returns false. A group is given as a single field, whose Raw is the group body,
+// and stray end group tags are skipped. There is no heap-memory allocation in this function.
+func (r Result) ForEach(iterator func(num protowire.Number, f Result) bool) error {
+	return r.forEach(nil, iterator)
+}
+
+// forEach is ForEach with the limits checked, the limiter may be nil for no limits.
+func (r Result) forEach(l *limiter, iterator func(num protowire.Number, f Result) bool) error {
+	_, err := r.iterFields(l, 0, anyNumber, func(f Result) bool {
+		if f.WireType == protowire.EndGroupType {
+			return true
+		}
+		return iterator(f.Number, f)
+	})
+	return err
 }
 
 // IterFields read through the binary data stored in r.Raw field-by-field, skipping all the fields
-// not interested in.
+// not interested in, and pbNumber 0 matches all the fields. The error of a malformed field is a
+// *ParseError.
 func (r Result) IterFields(pbNumber protowire.Number, resultSink func(r Result) bool) (int, error) {
+	return r.iterFields(nil, 0, pbNumber, resultSink)
+}
+
+// iterFields is IterFields with the limits checked, depth is the nesting depth of r.
+func (r Result) iterFields(l *limiter, depth int, pbNumber protowire.Number, resultSink func(r Result) bool) (int, error) {
+	if l == nil && pbNumber != anyNumber {
+		return r.iterNumber(pbNumber, resultSink)
+	}
 	var field Result
 	var consumedLength int
 	pb := r.Raw
+	base := r.rawStart()
 	// fields are not organized in order, so we need to iterate through all fields
 	for len(pb) > 0 {
-		fieldNumber, wireType, totalLen := protowire.ConsumeTag(pb)
-		if totalLen < 0 {
-			// error occurred when totalLen is negative
-			return consumedLength, ErrInvalidLength
+		fieldNumber, n, err := l.consumeField(pb, &field, depth)
+		if err != nil {
+			return consumedLength, locate(newParseError(err, pb, n), r)
 		}
-		pb = pb[totalLen:]
-		consumedLength += totalLen
-
-		field.WireType = wireType
-		field.Varint = 0
-		switch wireType {
-		case protowire.VarintType:
-			v, n := protowire.ConsumeVarint(pb)
-			if n < 0 {
-				return consumedLength, ErrInvalidLength
-			}
-			field.Varint = v
-			field.Raw = pb[:n]
+		if fieldNumber != pbNumber && pbNumber != anyNumber {
+			// field number not match, read for the following fields
 			pb = pb[n:]
 			consumedLength += n
-		case protowire.Fixed32Type:
-			if len(pb) < 4 {
-				return consumedLength, ErrInvalidLength
-			}
-			field.Raw = pb[:4]
-			pb = pb[4:]
-			consumedLength += 4
-		case protowire.Fixed64Type:
-			if len(pb) < 8 {
-				return consumedLength, ErrInvalidLength
-			}
-			field.Raw = pb[:8]
-			pb = pb[8:]
-			consumedLength += 8
-		case protowire.BytesType:
-			// consume length varint
-			v, n := protowire.ConsumeVarint(pb)
-			if n < 0 {
-				return consumedLength, ErrInvalidLength
-			}
-			pb = pb[n:]
-			field.Raw = pb[:v]
-			pb = pb[v:]
-			consumedLength += n + int(v)
-		case protowire.StartGroupType:
-			// deprecated start group type, we need to consume all values to the end of the group
-			var endGroupOccurred bool
-			subGroup := Result{
-				Raw: pb,
-			}
-			groupLength, err := subGroup.IterFields(fieldNumber, func(r Result) bool {
-				// consume all fields inside the group until end group tag occurs
-				if r.WireType == protowire.EndGroupType {
-					endGroupOccurred = true
-					return false // stop iteration
-				}
-				return true
-			})
-			if err != nil {
-				return consumedLength, err
-			}
-			if !endGroupOccurred {
-				return consumedLength, ErrEndGroupNotFound
-			}
-			field.Raw = pb[:groupLength]
-			pb = pb[groupLength:]
-			consumedLength += groupLength
-			// consume end group again
-			_, _, endGroupLen := protowire.ConsumeTag(pb)
-			if endGroupLen < 0 {
-				return consumedLength, ErrInvalidLength
+			continue
+		}
+		field.setField(fieldNumber, base+consumedLength, n)
+		pb = pb[n:]
+		if !resultSink(field) {
+			if field.WireType != protowire.EndGroupType {
+				// the end group tag is left to the caller, who uses the consumed length as group length
+				consumedLength += n
 			}
-			// no need to check the tag wire type and field number,
-			// because we already checked it in subGroup.IterFields
-			pb = pb[endGroupLen:]
-			consumedLength += endGroupLen
-		case protowire.EndGroupType:
-			// end group type, we need to consume the end group tag and give it to result consumer
-			consumedLength -= totalLen
-		default:
-			return consumedLength, errors.WithMessagef(ErrUnknownWireType, "wire_type=%d", wireType)
+			return consumedLength, nil
 		}
+		consumedLength += n
+	}
+	return consumedLength, nil
+}
 
-		if fieldNumber != pbNumber {
-			// field number not match, read for the following fields
+// iterNumber is iterFields of a single field number without limits, which is the hot path of
+// GetIter. The fields of other numbers are skipped by scanField, so that nothing is filled in for
+// them, the groups and the malformed fields are left to consumeField.
+func (r Result) iterNumber(pbNumber protowire.Number, resultSink func(r Result) bool) (int, error) {
+	var field Result
+	var consumedLength int
+	pb := r.Raw
+	for len(pb) > 0 {
+		n, found := scanField(pb, pbNumber, &field)
+		if n == 0 {
+			var fieldNumber protowire.Number
+			var err error
+			if fieldNumber, n, err = consumeField(pb, &field); err != nil {
+				return consumedLength, locate(newParseError(err, pb, n), r)
+			}
+			found = fieldNumber == pbNumber
+		}
+		if !found {
+			pb = pb[n:]
+			consumedLength += n
 			continue
 		}
+		field.setField(pbNumber, r.rawStart()+consumedLength, n)
+		pb = pb[n:]
 		if !resultSink(field) {
+			if field.WireType != protowire.EndGroupType {
+				consumedLength += n
+			}
 			return consumedLength, nil
 		}
+		consumedLength += n
 	}
 	return consumedLength, nil
 }
 
+// firstNumber is GetOneE of a single field number, which returns the field without any callback.
+func (r Result) firstNumber(pbNumber protowire.Number) (Result, error) {
+	var field Result
+	pb := r.Raw
+	for len(pb) > 0 {
+		n, found := scanField(pb, pbNumber, &field)
+		if n == 0 {
+			var fieldNumber protowire.Number
+			var err error
+			if fieldNumber, n, err = consumeField(pb, &field); err != nil {
+				return Result{WireType: InvalidWireType}, locate(newParseError(err, pb, n), r)
+			}
+			found = fieldNumber == pbNumber && field.WireType != protowire.EndGroupType
+		}
+		if found {
+			field.setField(pbNumber, r.rawStart()+len(r.Raw)-len(pb), n)
+			return field, nil
+		}
+		pb = pb[n:]
+	}
+	return Result{WireType: InvalidWireType}, nil
+}
+
+// scanField is the fast path of consumeField for the well-formed scalar and length-delimited
+// fields, found reports whether the field number is pbNumber, and field is filled only if it is.
+// 0 is returned for the other fields, which are left to consumeField.
+func scanField(pb []byte, pbNumber protowire.Number, field *Result) (n int, found bool) {
+	var fieldNumber protowire.Number
+	var wireType protowire.Type
+	if pb[0] >= 1<<3 && pb[0] < 0x80 {
+		fieldNumber, wireType, n = protowire.Number(pb[0]>>3), protowire.Type(pb[0]&7), 1
+	} else if fieldNumber, wireType, n = protowire.ConsumeTag(pb); n < 0 {
+		return 0, false
+	}
+	found = fieldNumber == pbNumber
+	var v uint64
+	var m int
+	switch wireType {
+	case protowire.VarintType:
+		if v, m = protowire.ConsumeVarint(pb[n:]); m < 0 {
+			return 0, false
+		}
+	case protowire.Fixed32Type:
+		if m = 4; len(pb) < n+m {
+			return 0, false
+		}
+	case protowire.Fixed64Type:
+		if m = 8; len(pb) < n+m {
+			return 0, false
+		}
+	case protowire.BytesType:
+		l, k := protowire.ConsumeVarint(pb[n:])
+		if k < 0 || l > uint64(len(pb)-n-k) {
+			return 0, false
+		}
+		n, m = n+k, int(l)
+	default:
+		return 0, false
+	}
+	if found {
+		field.WireType = wireType
+		field.Varint = v
+		field.Raw = pb[n : n+m]
+	}
+	return n + m, found
+}
+
+// consumeField reads a single field from the head of pb into field, returning its field number
+// and the total length consumed including the tag. This is the wire-type switch shared by all
+// the field walkers in this package. The field number and the position are not filled, see
+// setField. The errors are the sentinel ones, only the unknown wire type is wrapped with its value,
+// along with the offset of the malformed field in pb instead of the length, and the walkers
+// convert them with newParseError when they are not to be ignored.
+func consumeField(pb []byte, field *Result) (protowire.Number, int, error) {
+	var l *limiter
+	return l.consumeFieldDepth(pb, field, maxGroupDepth)
+}
+
+// consumeFieldDepth is consumeField with groupDepth more levels of nested groups allowed, the
+// limiter may be nil.
+func (l *limiter) consumeFieldDepth(pb []byte, field *Result, groupDepth int) (protowire.Number, int, error) {
+	var fieldNumber protowire.Number
+	var wireType protowire.Type
+	var totalLen int
+	if len(pb) > 0 && pb[0] >= 1<<3 && pb[0] < 0x80 {
+		// fast path for the single byte tags with valid field numbers, which are the most common ones
+		fieldNumber, wireType, totalLen = protowire.Number(pb[0]>>3), protowire.Type(pb[0]&7), 1
+	} else if fieldNumber, wireType, totalLen = protowire.ConsumeTag(pb); totalLen < 0 {
+		// error occurred when totalLen is negative
+		return 0, 0, ErrInvalidLength
+	}
+	pb = pb[totalLen:]
+
+	field.WireType = wireType
+	field.Varint = 0
+	switch wireType {
+	case protowire.VarintType:
+		v, n := protowire.ConsumeVarint(pb)
+		if n < 0 {
+			return fieldNumber, 0, ErrInvalidLength
+		}
+		field.Varint = v
+		field.Raw = pb[:n]
+		totalLen += n
+	case protowire.Fixed32Type:
+		if len(pb) < 4 {
+			return fieldNumber, 0, ErrInvalidLength
+		}
+		field.Raw = pb[:4]
+		totalLen += 4
+	case protowire.Fixed64Type:
+		if len(pb) < 8 {
+			return fieldNumber, 0, ErrInvalidLength
+		}
+		field.Raw = pb[:8]
+		totalLen += 8
+	case protowire.BytesType:
+		// consume length varint, the length is compared before being converted to int, so that
+		// it never overflows
+		v, n := protowire.ConsumeVarint(pb)
+		if n < 0 || v > uint64(len(pb)-n) {
+			return fieldNumber, 0, ErrInvalidLength
+		}
+		pb = pb[n:]
+		field.Raw = pb[:v]
+		totalLen += n + int(v)
+	case protowire.StartGroupType:
+		// deprecated start group type, we need to consume all values to the end of the group
+		if groupDepth <= 0 {
+			return fieldNumber, 0, ErrLimitExceeded
+		}
+		if span, ok := l.group(pb); ok {
+			field.Raw = pb[:span.length]
+			totalLen += span.length + span.endLength
+			break
+		}
+		groupLength, endGroupLen, err := l.consumeGroup(pb, fieldNumber, groupDepth-1)
+		if err == ErrEndGroupNotFound {
+			return fieldNumber, 0, err
+		} else if err != nil {
+			// groupLength is the offset of the malformed field inside the group
+			return fieldNumber, totalLen + groupLength, err
+		}
+		l.cacheGroup(pb, groupDepth, groupSpan{length: groupLength, endLength: endGroupLen})
+		field.Raw = pb[:groupLength]
+		totalLen += groupLength + endGroupLen
+	case protowire.EndGroupType:
+		// end group type, only the tag is consumed and the result is given to the consumer
+		field.Raw = nil
+	default:
+		return fieldNumber, 0, errors.WithMessagef(ErrUnknownWireType, "wire_type=%d", wireType)
+	}
+	return fieldNumber, totalLen, nil
+}
+
+// setField fills the field number and the position of the field, which are left to the walkers,
+// so that there is no cost for the fields skipped.
+func (r *Result) setField(number protowire.Number, start, length int) {
+	r.Number = number
+	r.Start, r.End = start, start+length
+}
+
+// tagLen returns the length of the tag at the head of pb, which is known to be valid.
+func tagLen(pb []byte) int {
+	n := 1
+	for n < len(pb) && pb[n-1] >= 0x80 {
+		n++
+	}
+	return n
+}
+
+// rawStart returns the offset of r.Raw in the root buffer, which is the base offset of the fields
+// inside. Raw ends the field except for the end group tag, which is as long as the start tag. It
+// is 0 for the results built by the callers, so the offsets of the fields inside are relative to
+// r.Raw.
+func (r Result) rawStart() int {
+	end := r.End
+	if r.WireType == protowire.StartGroupType {
+		end -= protowire.SizeTag(r.Number)
+	}
+	if start := end - len(r.Raw); start > 0 {
+		return start
+	}
+	return 0
+}
+
+// Tag returns the tag bytes of the field, root is the buffer given to the API. Nil is returned for
+// the results without tags, e.g. unpacked items.
+func (r Result) Tag(root []byte) []byte {
+	if r.Number == 0 || r.End-r.Start <= len(r.Raw) || r.End > len(root) {
+		return nil
+	}
+	return root[r.Start : r.Start+tagLen(root[r.Start:r.End])]
+}
+
+// consumeGroup consumes all fields inside a group until the end group tag of groupNumber occurs,
+// returning the length of the group body and the length of the end group tag. On error, the
+// offset of the malformed field is returned instead of the length of the group body.
+func (l *limiter) consumeGroup(pb []byte, groupNumber protowire.Number, groupDepth int) (int, int, error) {
+	var field Result
+	var groupLength int
+	for len(pb) > 0 {
+		if err := l.countField(); err != nil {
+			return groupLength, 0, err
+		}
+		fieldNumber, n, err := l.consumeFieldDepth(pb, &field, groupDepth)
+		if err != nil {
+			return groupLength + n, 0, err
+		}
+		if field.WireType == protowire.EndGroupType {
+			if fieldNumber != groupNumber {
+				// the offset of the end group tag not matching the group
+				return groupLength, 0, ErrEndGroupMismatch
+			}
+			return groupLength, n, nil
+		}
+		pb = pb[n:]
+		groupLength += n
+	}
+	return 0, 0, ErrEndGroupNotFound
+}
+
 // Varints - normal
 
 func (r Result) Int32() int32 {
@@ -393,10 +803,14 @@ func (r Result) UnpackVarint() []Result {
 		if n < 0 {
 			break
 		}
+		start := r.itemStart(pb)
 		results = append(results, Result{
 			WireType: protowire.VarintType,
 			Varint:   v,
 			Raw:      pb[:n],
+			Number:   r.Number,
+			Start:    start,
+			End:      start + n,
 		})
 		pb = pb[n:]
 	}
@@ -411,9 +825,13 @@ func (r Result) UnpackFixed32() []Result {
 	pb := r.Raw
 	results := make([]Result, 0, len(pb)/4)
 	for len(pb) >= 4 {
+		start := r.itemStart(pb)
 		results = append(results, Result{
 			WireType: protowire.Fixed32Type,
 			Raw:      pb[:4],
+			Number:   r.Number,
+			Start:    start,
+			End:      start + 4,
 		})
 		pb = pb[4:]
 	}
@@ -428,11 +846,20 @@ func (r Result) UnpackFixed64() []Result {
 	pb := r.Raw
 	results := make([]Result, 0, len(pb)/8)
 	for len(pb) >= 8 {
+		start := r.itemStart(pb)
 		results = append(results, Result{
 			WireType: protowire.Fixed64Type,
 			Raw:      pb[:8],
+			Number:   r.Number,
+			Start:    start,
+			End:      start + 8,
 		})
 		pb = pb[8:]
 	}
 	return results
 }
+
+// itemStart returns the offset of the packed item at the head of pb, which is sliced from r.Raw.
+func (r Result) itemStart(pb []byte) int {
+	return r.rawStart() + len(r.Raw) - len(pb)
+}
@@ -1,12 +1,14 @@
 package gpb
 
 import (
+	"bytes"
 	"testing"
 
 	"github.com/ywx217/gpb/internal/testprotos"
 
 	"github.com/samber/lo"
 	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/encoding/protowire"
 	"google.golang.org/protobuf/proto"
 )
 
@@ -491,6 +493,225 @@ func TestEncodeDecode6(t *testing.T) {
 	})
 }
 
+// TestGetLast payloads are concatenated, and the result should be the same as the merged message.
+func TestGetLast(t *testing.T) {
+	a, err := proto.Marshal(initGoTest(false))
+	require.NoError(t, err)
+	b, err := proto.MarshalOptions{AllowPartial: true}.Marshal(&testprotos.GoTest{
+		RequiredField:   &testprotos.GoTestField{Type: proto.String("merged")},
+		F_Int32Required: proto.Int32(99),
+	})
+	require.NoError(t, err)
+	bs := append(a, b...)
+
+	var merged testprotos.GoTest
+	require.NoError(t, proto.Unmarshal(bs, &merged))
+	require.Equal(t, merged.GetF_Int32Required(), GetLast(bs, 11).Int32())
+	require.Equal(t, merged.GetRequiredField().GetLabel(), GetLast(bs, 4, 1).String())
+	require.Equal(t, merged.GetRequiredField().GetType(), GetLast(bs, 4, 2).String())
+	require.Equal(t, merged.GetF_StringRequired(), GetLast(bs, 19).String())
+	require.Equal(t, "type", GetOne(bs, 4, 2).String(), "GetOne returns the first value")
+	require.False(t, GetLast(bs, 6, 1).Exist())
+}
+
+func TestGetOneE(t *testing.T) {
+	bs, err := proto.Marshal(initGoTest(false))
+	require.NoError(t, err)
+
+	r, err := GetOneE(bs, 4, 1)
+	require.NoError(t, err)
+	require.Equal(t, "label", r.String())
+	r, err = GetOneE(bs, 11, 1)
+	require.NoError(t, err, "scalars are not descended into")
+	require.False(t, r.Exist())
+
+	r, err = GetOneE(bs[:len(bs)-1], 1000)
+	require.ErrorIs(t, err, ErrInvalidLength)
+	require.False(t, r.Exist())
+	_, err = GetOneE([]byte{0x0e}, 1)
+	require.ErrorIs(t, err, ErrUnknownWireType)
+	_, err = GetOneE([]byte{0x0b, 0x08, 0x01}, 1)
+	require.ErrorIs(t, err, ErrEndGroupNotFound)
+	_, err = GetOneE(append([]byte{0x22, 0x02, 0x0a, 0x05}, bs...), 4, 1)
+	require.ErrorIs(t, err, ErrInvalidLength, "malformed embedded message")
+}
+
+func TestGetAllE(t *testing.T) {
+	msg := initGoTest(false)
+	msg.F_Int32Repeated = []int32{1, 2, 3}
+	bs, err := proto.Marshal(msg)
+	require.NoError(t, err)
+
+	results, err := GetAllE(bs, 21)
+	require.NoError(t, err)
+	require.Len(t, results, 3)
+
+	// the payload is truncated in the last field
+	results, err = GetAllE(bs[:len(bs)-1], 21)
+	require.ErrorIs(t, err, ErrInvalidLength)
+	require.Len(t, results, 3, "values found before the error are returned")
+	require.Len(t, GetAll(bs[:len(bs)-1], 21), 3)
+}
+
+// requirePosition checks the field number and the offsets of the result read from root.
+func requirePosition(t *testing.T, root []byte, r Result) {
+	field := root[r.Start:r.End]
+	number, wireType, n := protowire.ConsumeTag(field)
+	require.Equal(t, r.Number, number)
+	require.Equal(t, r.WireType, wireType)
+	require.Equal(t, len(field), n+protowire.ConsumeFieldValue(number, wireType, field[n:]))
+	require.Equal(t, field[:n], r.Tag(root))
+	require.Equal(t, r.Raw, root[r.rawStart():r.rawStart()+len(r.Raw)])
+}
+
+func TestResultPosition(t *testing.T) {
+	msg := initGoTest(true)
+	msg.RepeatedField = []*testprotos.GoTestField{initGoTestField(), initGoTestField()}
+	msg.F_Int32RepeatedPacked = []int32{1, 1 << 20, -1}
+	msg.Repeatedgroup = []*testprotos.GoTest_RepeatedGroup{initGoTestRepeatedGroup(), initGoTestRepeatedGroup()}
+	bs, err := proto.Marshal(msg)
+	require.NoError(t, err)
+
+	for _, numbers := range [][]protowire.Number{{1}, {5}, {5, 2}, {13}, {14}, {70}, {70, 71}, {80, 81}} {
+		results := GetAll(bs, numbers...)
+		require.NotEmpty(t, results)
+		for _, r := range results {
+			requirePosition(t, bs, r)
+		}
+	}
+	requirePosition(t, bs, Get(bs, "5.#last.1"))
+	requirePosition(t, bs, MustCompile("80.#1.81").GetOne(bs))
+	results := make([]Result, 2)
+	require.NoError(t, MustCompileMany("4.2", "*.81").GetMany(bs, results))
+	requirePosition(t, bs, results[0])
+	requirePosition(t, bs, results[1])
+
+	// the offsets are absolute in the root buffer
+	r := GetOne(bs, 5)
+	require.Equal(t, GetOne(bs, 5, 1), r.GetOne(1))
+	require.Equal(t, GetOne(bs, 80, 81), GetOne(bs, 80).GetOne(81))
+
+	packed := GetOne(bs, 51)
+	requirePosition(t, bs, packed)
+	items := packed.UnpackVarint()
+	require.Len(t, items, 3)
+	for _, item := range items {
+		require.Equal(t, protowire.Number(51), item.Number)
+		require.Equal(t, item.Raw, bs[item.Start:item.End])
+		require.Nil(t, item.Tag(bs))
+	}
+
+	count := Get(bs, "5.#")
+	require.Equal(t, protowire.Number(0), count.Number)
+	require.Nil(t, count.Tag(bs))
+}
+
+func TestResultPositionResliced(t *testing.T) {
+	msg := initGoTest(false)
+	msg.Repeatedgroup = []*testprotos.GoTest_RepeatedGroup{initGoTestRepeatedGroup()}
+	bs, err := proto.Marshal(msg)
+	require.NoError(t, err)
+
+	// the offsets do not depend on the capacity of Raw
+	group := GetOne(bs, 80)
+	group.Raw = group.Raw[:len(group.Raw):len(group.Raw)]
+	require.Equal(t, GetOne(bs, 80, 81), group.GetOne(81))
+	requirePosition(t, bs, group.GetOne(81))
+
+	// the offsets of the fields in the results built by callers are relative to Raw
+	built := Result{WireType: protowire.StartGroupType, Number: 80, Raw: append([]byte{}, group.Raw...)}
+	field := built.GetOne(81)
+	require.Equal(t, "repeated", field.String())
+	require.Equal(t, 0, field.Start)
+	requirePosition(t, built.Raw, field)
+}
+
+func TestForEach(t *testing.T) {
+	bs, err := proto.Marshal(initGoTest(true))
+	require.NoError(t, err)
+
+	// the expected field numbers are read with protowire
+	var expected []protowire.Number
+	for pb := bs; len(pb) > 0; {
+		number, wireType, n := protowire.ConsumeTag(pb)
+		pb = pb[n:]
+		pb = pb[protowire.ConsumeFieldValue(number, wireType, pb):]
+		expected = append(expected, number)
+	}
+	var numbers []protowire.Number
+	require.NoError(t, ForEach(bs, func(num protowire.Number, f Result) bool {
+		requirePosition(t, bs, f)
+		numbers = append(numbers, num)
+		if num == 70 {
+			require.Equal(t, protowire.StartGroupType, f.WireType)
+			require.Equal(t, "required", f.GetOne(71).String())
+		}
+		return true
+	}))
+	require.Equal(t, expected, numbers)
+
+	numbers = numbers[:0]
+	require.NoError(t, GetOne(bs, 4).ForEach(func(num protowire.Number, f Result) bool {
+		numbers = append(numbers, num)
+		return true
+	}))
+	require.Equal(t, []protowire.Number{1, 2}, numbers)
+
+	numbers = numbers[:0]
+	require.NoError(t, ForEach(bs, func(num protowire.Number, f Result) bool {
+		numbers = append(numbers, num)
+		return len(numbers) < 3
+	}))
+	require.Equal(t, expected[:3], numbers)
+
+	// stray end group tags are skipped
+	numbers = numbers[:0]
+	require.NoError(t, ForEach([]byte{0x08, 0x01, 0x0c, 0x10, 0x02}, func(num protowire.Number, f Result) bool {
+		numbers = append(numbers, num)
+		return true
+	}))
+	require.Equal(t, []protowire.Number{1, 2}, numbers)
+
+	numbers = numbers[:0]
+	err = ForEach(bs[:len(bs)-1], func(num protowire.Number, f Result) bool {
+		numbers = append(numbers, num)
+		return true
+	})
+	require.ErrorIs(t, err, ErrInvalidLength)
+	require.Equal(t, expected[:len(expected)-1], numbers)
+
+	allocs := testing.AllocsPerRun(100, func() {
+		_ = ForEach(bs, func(protowire.Number, Result) bool { return true })
+	})
+	require.Zero(t, allocs)
+}
+
+func TestHostileInput(t *testing.T) {
+	// groups nested too deep
+	deep := bytes.Repeat([]byte{0x0b}, maxGroupDepth+1)
+	deep = append(deep, bytes.Repeat([]byte{0x0c}, maxGroupDepth+1)...)
+	_, err := GetAllE(deep, 1)
+	require.ErrorIs(t, err, ErrLimitExceeded)
+	pe := requireParseError(t, err)
+	require.Equal(t, maxGroupDepth, pe.Offset)
+	require.Len(t, pe.Path, maxGroupDepth)
+	results, err := GetAllE(deep[1:len(deep)-1], 1)
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+
+	// lengths overflowing the buffer and int
+	for _, pb := range [][]byte{
+		{0x0a, 0xff, 0xff, 0xff, 0xff, 0x0f},
+		{0x0a, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x01},
+	} {
+		_, err = GetAllE(pb, 1)
+		require.ErrorIs(t, err, ErrInvalidLength)
+		require.False(t, Get(pb, "1.1").Exist())
+	}
+
+	require.False(t, GetOne([]byte{0x08, 0x01}).Exist(), "empty path")
+}
+
 // payload size: 2 bytes
 func benchmarkTiny(b *testing.B, run func(*testing.B, []byte)) {
 	b.StopTimer()
@@ -571,3 +792,71 @@ func BenchmarkGpbSmall(b *testing.B) {
 		}
 	})
 }
+
+func BenchmarkGpbSmallPath(b *testing.B) {
+	benchmarkSmall(b, func(b *testing.B, raw []byte) {
+		var sum int
+		for i := 0; i < b.N; i++ {
+			sum += int(Get(raw, "11").Int32())
+		}
+	})
+}
+
+func BenchmarkGpbSmallQuery(b *testing.B) {
+	q := MustCompile("11")
+	benchmarkSmall(b, func(b *testing.B, raw []byte) {
+		var sum int
+		for i := 0; i < b.N; i++ {
+			sum += int(q.GetOne(raw).Int32())
+		}
+	})
+}
+
+func BenchmarkGpbSmallNested(b *testing.B) {
+	benchmarkSmall(b, func(b *testing.B, raw []byte) {
+		var sum int
+		for i := 0; i < b.N; i++ {
+			sum += len(GetOne(raw, 80, 81).Raw)
+		}
+	})
+}
+
+func BenchmarkGpbSmallNestedQuery(b *testing.B) {
+	q := MustCompile("80.81")
+	benchmarkSmall(b, func(b *testing.B, raw []byte) {
+		var sum int
+		for i := 0; i < b.N; i++ {
+			sum += len(q.GetOne(raw).Raw)
+		}
+	})
+}
+
+var benchmarkManyPaths = []string{
+	"1", "4.1", "4.2", "10", "11", "12", "13", "14", "15", "16", "17", "18", "19", "101", "70.71",
+}
+
+func BenchmarkGpbSmallManyPaths(b *testing.B) {
+	queries := lo.Map(benchmarkManyPaths, func(path string, _ int) *Query { return MustCompile(path) })
+	benchmarkSmall(b, func(b *testing.B, raw []byte) {
+		var sum int
+		for i := 0; i < b.N; i++ {
+			for _, q := range queries {
+				sum += len(q.GetOne(raw).Raw)
+			}
+		}
+	})
+}
+
+func BenchmarkGpbSmallGetMany(b *testing.B) {
+	m := MustCompileMany(benchmarkManyPaths...)
+	results := make([]Result, m.Len())
+	benchmarkSmall(b, func(b *testing.B, raw []byte) {
+		var sum int
+		for i := 0; i < b.N; i++ {
+			_ = m.GetMany(raw, results)
+			for _, r := range results {
+				sum += len(r.Raw)
+			}
+		}
+	})
+}
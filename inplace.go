@@ -0,0 +1,62 @@
+package gpb
+
+import (
+	"github.com/pkg/errors"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// SetInPlace overwrites the value of the result in the buffer it is sliced from, without any
+// allocation or copy. The value must be of the same wire type and encoded size as the result,
+// e.g. a varint can only be replaced by one of the same width, and a string by one of the same
+// length. The result itself is not updated, get it again to read the new value.
+func (r Result) SetInPlace(v Value) error {
+	if !r.Exist() {
+		return errors.WithMessage(ErrNotInPlace, "result does not exist")
+	}
+	if r.WireType != v.wireType {
+		return errors.WithMessagef(ErrNotInPlace, "wire type %d is set to %d", r.WireType, v.wireType)
+	}
+	switch v.wireType {
+	case protowire.VarintType:
+		if size := protowire.SizeVarint(v.scalar); size != len(r.Raw) {
+			return errors.WithMessagef(ErrNotInPlace, "varint of %d bytes is set to %d bytes", len(r.Raw), size)
+		}
+		protowire.AppendVarint(r.Raw[:0], v.scalar)
+	case protowire.Fixed32Type:
+		protowire.AppendFixed32(r.Raw[:0], uint32(v.scalar))
+	case protowire.Fixed64Type:
+		protowire.AppendFixed64(r.Raw[:0], v.scalar)
+	default:
+		if len(v.raw) != len(r.Raw) {
+			return errors.WithMessagef(ErrNotInPlace, "%d bytes are set to %d bytes", len(r.Raw), len(v.raw))
+		}
+		copy(r.Raw, v.raw)
+	}
+	return nil
+}
+
+// SetVarintInPlace overwrites the varint in place, the new value must be encoded in the same
+// number of bytes.
+func (r Result) SetVarintInPlace(v uint64) error {
+	return r.SetInPlace(ValueOfUint64(v))
+}
+
+// SetFixed32InPlace overwrites the fixed32 in place.
+func (r Result) SetFixed32InPlace(v uint32) error {
+	return r.SetInPlace(ValueOfFixed32(v))
+}
+
+// SetFloat32InPlace overwrites the float in place.
+func (r Result) SetFloat32InPlace(v float32) error {
+	return r.SetInPlace(ValueOfFloat32(v))
+}
+
+// SetFixed64InPlace overwrites the fixed64 in place.
+func (r Result) SetFixed64InPlace(v uint64) error {
+	return r.SetInPlace(ValueOfFixed64(v))
+}
+
+// SetFloat64InPlace overwrites the double in place.
+func (r Result) SetFloat64InPlace(v float64) error {
+	return r.SetInPlace(ValueOfFloat64(v))
+}
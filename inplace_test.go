@@ -0,0 +1,64 @@
+package gpb
+
+import (
+	"testing"
+
+	"github.com/ywx217/gpb/internal/testprotos"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestSetInPlace(t *testing.T) {
+	msg := initGoTest(true)
+	msg.F_Int32RepeatedPacked = []int32{1, 2}
+	bs, err := proto.Marshal(msg)
+	require.NoError(t, err)
+	size := len(bs)
+
+	require.NoError(t, GetOne(bs, 13).SetFixed32InPlace(3300))
+	require.NoError(t, GetOne(bs, 17).SetFloat32InPlace(1.5))
+	require.NoError(t, GetOne(bs, 14).SetFixed64InPlace(6400))
+	require.NoError(t, GetOne(bs, 18).SetFloat64InPlace(-2.5))
+	require.NoError(t, GetOne(bs, 1).SetVarintInPlace(uint64(testprotos.GoTest_TUPLE)))
+	require.NoError(t, GetOne(bs, 19).SetInPlace(ValueOfString("STRING")))
+	require.NoError(t, GetOne(bs, 70, 71).SetInPlace(ValueOfString("REQUIRED")))
+	require.NoError(t, GetOne(bs, 105).SetInPlace(ValueOfSFixed64(-128)))
+	for i, item := range GetOne(bs, 51).UnpackVarint() {
+		require.NoError(t, item.SetInPlace(ValueOfInt32(int32(i+5))))
+	}
+	require.Len(t, bs, size)
+
+	actual := unmarshalGoTest(t, bs)
+	require.Equal(t, uint32(3300), actual.GetF_Fixed32Required())
+	require.Equal(t, float32(1.5), actual.GetF_FloatRequired())
+	require.Equal(t, uint64(6400), actual.GetF_Fixed64Required())
+	require.Equal(t, -2.5, actual.GetF_DoubleRequired())
+	require.Equal(t, testprotos.GoTest_TUPLE, actual.GetKind())
+	require.Equal(t, "STRING", actual.GetF_StringRequired())
+	require.Equal(t, "REQUIRED", actual.GetRequiredgroup().GetRequiredField())
+	require.Equal(t, int64(-128), actual.GetF_Sfixed64Required())
+	require.Equal(t, []int32{5, 6}, actual.F_Int32RepeatedPacked)
+
+	allocs := testing.AllocsPerRun(100, func() {
+		_ = GetOne(bs, 13).SetFixed32InPlace(3300)
+	})
+	require.Zero(t, allocs)
+}
+
+func TestSetInPlaceError(t *testing.T) {
+	bs, err := proto.Marshal(initGoTest(false))
+	require.NoError(t, err)
+	original := append([]byte{}, bs...)
+
+	// the width of the varint changes
+	require.ErrorIs(t, GetOne(bs, 1).SetVarintInPlace(128), ErrNotInPlace)
+	require.ErrorIs(t, GetOne(bs, 15).SetVarintInPlace(1), ErrNotInPlace)
+	require.ErrorIs(t, GetOne(bs, 19).SetInPlace(ValueOfString("str")), ErrNotInPlace)
+	// the wire type differs
+	require.ErrorIs(t, GetOne(bs, 13).SetFixed64InPlace(1), ErrNotInPlace)
+	require.ErrorIs(t, GetOne(bs, 1).SetFixed32InPlace(1), ErrNotInPlace)
+	require.ErrorIs(t, GetOne(bs, 1000).SetVarintInPlace(1), ErrNotInPlace)
+	require.EqualError(t, GetOne(bs, 1).SetVarintInPlace(128), "varint of 1 bytes is set to 2 bytes: can not be set in place")
+	require.Equal(t, original, bs)
+}
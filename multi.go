@@ -0,0 +1,296 @@
+package gpb
+
+import (
+	"sort"
+
+	"github.com/pkg/errors"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+const (
+	// inlineTrieChildren nodes with no more children than this are walked without heap allocation
+	inlineTrieChildren = 16
+	// maxDenseTrieNumber children are looked up by a dense table when all the field numbers are
+	// not greater than this, otherwise by binary search
+	maxDenseTrieNumber = 1024
+)
+
+// MultiQuery is a set of compiled paths, which are extracted from a message in a single pass.
+// The paths are merged into a trie over field numbers, and each field read is dispatched to
+// every path that wants it. MultiQuery is immutable and safe to be shared across goroutines.
+type MultiQuery struct {
+	paths    []string
+	root     trieNode
+	counting bool // some paths end with `#`, which requires reading through the whole message
+	limits   Limits
+}
+
+type trieNode struct {
+	pathSegment
+	leaves   []int // indexes of the paths ending at this node
+	counts   []int // indexes of the paths ending at this node with `#`
+	children []trieNode
+	// children are sorted by field number, so the wildcards come first and the children of the
+	// same field number are adjacent
+	wildcards int
+	dense     []int32 // field number to the index of the first child, -1 for not found
+}
+
+// CompileMany parses the paths into a MultiQuery.
+func CompileMany(paths ...string) (*MultiQuery, error) {
+	return compileMany(paths, false)
+}
+
+// MustCompileMany is like CompileMany but panics if any of the paths is invalid.
+func MustCompileMany(paths ...string) *MultiQuery {
+	m, err := CompileMany(paths...)
+	if err != nil {
+		panic(errors.WithMessage(err, "gpb: compile"))
+	}
+	return m
+}
+
+// compileMany builds the trie of the paths, invalid paths are skipped when lenient is set.
+func compileMany(paths []string, lenient bool) (*MultiQuery, error) {
+	m := &MultiQuery{paths: paths}
+	for i, path := range paths {
+		p, err := parsePath(path, nil, resolveFieldNumber)
+		if err != nil {
+			if lenient {
+				continue
+			}
+			return nil, err
+		}
+		node := &m.root
+		for _, seg := range p.segments {
+			node = node.child(seg)
+		}
+		if p.count {
+			node.counts = append(node.counts, i)
+			m.counting = true
+		} else {
+			node.leaves = append(node.leaves, i)
+		}
+	}
+	m.root.build()
+	return m, nil
+}
+
+// build sorts the children and builds the lookup table recursively.
+func (n *trieNode) build() {
+	sort.SliceStable(n.children, func(i, j int) bool {
+		return n.children[i].number < n.children[j].number
+	})
+	for i := range n.children {
+		n.children[i].build()
+		if n.children[i].number == anyNumber {
+			n.wildcards++
+		}
+	}
+	if len(n.children) == 0 || n.children[len(n.children)-1].number > maxDenseTrieNumber {
+		return
+	}
+	n.dense = make([]int32, n.children[len(n.children)-1].number+1)
+	for i := range n.dense {
+		n.dense[i] = -1
+	}
+	for i := len(n.children) - 1; i >= n.wildcards; i-- {
+		n.dense[n.children[i].number] = int32(i)
+	}
+}
+
+// lookup returns the range of the children with the field number, wildcards excluded.
+func (n *trieNode) lookup(fieldNumber protowire.Number) (int, int) {
+	var start int
+	if n.dense != nil {
+		if int(fieldNumber) >= len(n.dense) || n.dense[fieldNumber] < 0 {
+			return 0, 0
+		}
+		start = int(n.dense[fieldNumber])
+	} else {
+		// binary search for the first child with the field number
+		lo, hi := n.wildcards, len(n.children)
+		for lo < hi {
+			mid := int(uint(lo+hi) >> 1)
+			if n.children[mid].number < fieldNumber {
+				lo = mid + 1
+			} else {
+				hi = mid
+			}
+		}
+		start = lo
+	}
+	end := start
+	for end < len(n.children) && n.children[end].number == fieldNumber {
+		end++
+	}
+	return start, end
+}
+
+// child finds or creates the child node of the segment.
+func (n *trieNode) child(seg pathSegment) *trieNode {
+	for i := range n.children {
+		if n.children[i].pathSegment == seg {
+			return &n.children[i]
+		}
+	}
+	n.children = append(n.children, trieNode{pathSegment: seg})
+	return &n.children[len(n.children)-1]
+}
+
+// Len returns the number of paths.
+func (m *MultiQuery) Len() int {
+	return len(m.paths)
+}
+
+// GetMany reads through pb once, and fills results with the first value of each path in the
+// same order as the compiled paths, paths ending with `#` get the count instead. Paths with
+// nothing matched get a Result with InvalidWireType. There is no heap-memory allocation in
+// this function, unless a message in the trie has more than 16 different child segments.
+func (m *MultiQuery) GetMany(pb []byte, results []Result) error {
+	if len(results) < len(m.paths) {
+		return errors.Errorf("results length %d is less than the %d paths", len(results), len(m.paths))
+	}
+	w := multiWalker{results: results, counting: m.counting, limits: newLimiter(m.limits)}
+	for i := range m.paths {
+		results[i] = Result{WireType: InvalidWireType}
+	}
+	m.root.init(&w)
+	if w.pending == 0 && !w.counting {
+		return nil
+	}
+	return locate(w.walk(Result{Raw: pb}, &m.root), Result{Raw: pb})
+}
+
+// init initializes the results of the paths in the subtree.
+func (n *trieNode) init(w *multiWalker) {
+	w.pending += len(n.leaves)
+	for _, i := range n.counts {
+		w.results[i] = Result{WireType: protowire.VarintType}
+	}
+	for i := range n.children {
+		n.children[i].init(w)
+	}
+}
+
+type multiWalker struct {
+	results   []Result
+	pending   int // number of the paths still waiting for the first value
+	counting  bool
+	stopped   bool
+	limits    *limiter
+	depth     int // nesting depth of the message being walked
+	wildcards int // number of the wildcard segments above the message being walked
+}
+
+// walk iterates through the fields in msg, and dispatches them to the children of the node.
+func (w *multiWalker) walk(msg Result, node *trieNode) error {
+	var field Result
+	var inlineOccurrences [inlineTrieChildren]int
+	var inlineLasts [inlineTrieChildren]Result
+	occurrences, lasts := inlineOccurrences[:], inlineLasts[:]
+	if len(node.children) > inlineTrieChildren {
+		occurrences = make([]int, len(node.children))
+		lasts = make([]Result, len(node.children))
+	}
+	base := msg.rawStart()
+	for pb := msg.Raw; len(pb) > 0; {
+		fieldNumber, n, err := w.limits.consumeField(pb, &field, w.depth)
+		if err != nil {
+			if err != ErrLimitExceeded && w.wildcards > 0 {
+				// ignored by the wildcard segment, see visit
+				return err
+			}
+			return newParseError(err, pb, n)
+		}
+		start, end := node.lookup(fieldNumber)
+		if field.WireType == protowire.EndGroupType || (start == end && node.wildcards == 0) {
+			pb = pb[n:]
+			continue
+		}
+		field.setField(fieldNumber, base+len(msg.Raw)-len(pb), n)
+		pb = pb[n:]
+		for i := 0; i < node.wildcards; i++ {
+			if err := w.dispatch(field, node, i, occurrences, lasts); err != nil || w.stopped {
+				return err
+			}
+		}
+		for i := start; i < end; i++ {
+			if err := w.dispatch(field, node, i, occurrences, lasts); err != nil || w.stopped {
+				return err
+			}
+		}
+	}
+	for i := range node.children {
+		if node.children[i].selector != selectLast || occurrences[i] == 0 {
+			continue
+		}
+		if err := w.visit(lasts[i], &node.children[i]); err != nil || w.stopped {
+			return err
+		}
+	}
+	return nil
+}
+
+// dispatch applies the selector of the i-th child to the field, and visits the child if
+// the field is selected.
+func (w *multiWalker) dispatch(field Result, node *trieNode, i int, occurrences []int, lasts []Result) error {
+	child := &node.children[i]
+	index := occurrences[i]
+	occurrences[i]++
+	switch child.selector {
+	case selectIndex:
+		if index != child.index {
+			return nil
+		}
+	case selectLast:
+		// the last occurrence is unknown until all the fields are read
+		lasts[i] = field
+		return nil
+	case selectRange:
+		if index < child.index || (child.end >= 0 && index >= child.end) {
+			return nil
+		}
+	}
+	return w.visit(field, child)
+}
+
+// visit feeds the field to the paths ending at the node, and descends into it for the longer
+// paths.
+func (w *multiWalker) visit(field Result, node *trieNode) error {
+	for _, i := range node.leaves {
+		if !w.results[i].Exist() {
+			w.results[i] = field
+			w.pending--
+		}
+	}
+	for _, i := range node.counts {
+		w.results[i].Varint++
+	}
+	if w.pending == 0 && !w.counting {
+		// all the paths are satisfied
+		w.stopped = true
+		return nil
+	}
+	if len(node.children) == 0 {
+		return nil
+	}
+	if field.WireType != protowire.BytesType && field.WireType != protowire.StartGroupType {
+		// scalars have no fields inside
+		return nil
+	}
+	if node.number == anyNumber {
+		w.wildcards++
+	}
+	w.depth++
+	err := w.walk(field, node)
+	w.depth--
+	if node.number == anyNumber {
+		w.wildcards--
+		if ignorable(err) {
+			// length-delimited fields matched by a wildcard are not necessarily messages
+			return nil
+		}
+	}
+	return enclose(err, field.Number)
+}
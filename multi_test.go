@@ -0,0 +1,72 @@
+package gpb
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMultiQuery(t *testing.T) {
+	bs := marshalRepeatedGoTest(t)
+	paths := []string{
+		"1", "4.1", "4.2", "4", "5.1", "5.#1.1", "5.#last.2", "5.#1:.1.#",
+		"21.#", "21.#last", "70.71", "*.2", "6.1", "19", "4.1", "2000",
+	}
+
+	m, err := CompileMany(paths...)
+	require.NoError(t, err)
+	require.Equal(t, len(paths), m.Len())
+	results := make([]Result, m.Len())
+	require.NoError(t, m.GetMany(bs, results))
+	for i, path := range paths {
+		require.Equal(t, Get(bs, path), results[i], path)
+	}
+
+	// results are reset before filled
+	require.NoError(t, m.GetMany(bs[:0], results))
+	for i, path := range paths {
+		require.Equal(t, Get(bs[:0], path), results[i], path)
+	}
+
+	require.Error(t, m.GetMany(bs, results[:1]))
+	_, err = CompileMany("1", "1.#x")
+	require.ErrorIs(t, err, ErrInvalidPath)
+	require.Panics(t, func() { MustCompileMany("") })
+}
+
+func TestMultiQueryManyChildren(t *testing.T) {
+	bs := marshalRepeatedGoTest(t)
+	var paths []string
+	for _, number := range []string{"1", "4", "10", "11", "12", "13", "14", "15", "16", "17", "18", "19", "21", "101", "102", "103", "104", "105", "70"} {
+		paths = append(paths, number, number+".#")
+	}
+	results := MustCompileMany(paths...)
+	values := make([]Result, len(paths))
+	require.NoError(t, results.GetMany(bs, values))
+	for i, path := range paths {
+		require.Equal(t, Get(bs, path), values[i], path)
+	}
+}
+
+func TestMultiQueryError(t *testing.T) {
+	bs := marshalRepeatedGoTest(t)
+	results := make([]Result, 2)
+	m := MustCompileMany("1", "1000")
+	require.ErrorIs(t, m.GetMany(bs[:len(bs)-1], results), ErrInvalidLength)
+	require.Equal(t, Get(bs, "1"), results[0], "values found before the error are kept")
+	require.False(t, results[1].Exist())
+
+	results = GetMany(bs, "4.1", "4..1")
+	require.Equal(t, "label", results[0].String())
+	require.False(t, results[1].Exist())
+}
+
+func TestMultiQueryNoAlloc(t *testing.T) {
+	bs := marshalRepeatedGoTest(t)
+	m := MustCompileMany("1", "4.1", "5.#last.2", "21.#", "70.71", "*.1")
+	results := make([]Result, m.Len())
+	allocs := testing.AllocsPerRun(100, func() {
+		_ = m.GetMany(bs, results)
+	})
+	require.Zero(t, allocs)
+}
@@ -0,0 +1,187 @@
+package gpb
+
+import (
+	"github.com/pkg/errors"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// Options configures how messages are walked through. The zero value behaves the same as the
+// package functions, e.g. `gpb.Options{}.GetAllE(pb, 4, 1)` equals to `gpb.GetAllE(pb, 4, 1)`.
+type Options struct {
+	Limits Limits
+}
+
+// Limits bounds the resources consumed by walking through a message, so that untrusted payloads
+// can be parsed without the risk of DoS. A walk exceeding any of the limits stops with a
+// *ParseError wrapping ErrLimitExceeded, which locates the field not read. Zero values mean no
+// limit, except that groups are nested up to protowire.DefaultRecursionLimit levels by default.
+type Limits struct {
+	// MaxDepth the max nesting depth of the messages descended into, the root message is at depth 0
+	MaxDepth int
+	// MaxGroupDepth the max nesting depth of groups, including the ones skipped
+	MaxGroupDepth int
+	// MaxFields the max number of fields read, including the ones skipped and the ones inside the
+	// groups skipped, the end group tags are counted as fields
+	MaxFields int
+	// MaxBytes the max number of bytes read, the bytes of a message are counted again when it is
+	// descended into
+	MaxBytes int
+}
+
+// limiter counts the resources consumed by a single walk. A nil limiter checks nothing but the
+// default group depth.
+type limiter struct {
+	Limits
+	fields int
+	bytes  int
+	// cacheGroups whether the lengths of the nested groups are cached in groups, by the head of the
+	// group body, so that the walkers descending into the groups level by level do not scan the
+	// nested groups again, which costs the square of the depth
+	cacheGroups bool
+	groups      map[*byte]groupSpan
+}
+
+// groupSpan the lengths of the body and the end group tag of a group.
+type groupSpan struct {
+	length, endLength int
+}
+
+// newLimiter returns nil when there is no limit, so that the walkers take the fast path.
+func newLimiter(limits Limits) *limiter {
+	if limits == (Limits{}) {
+		return nil
+	}
+	if limits.MaxGroupDepth <= 0 {
+		limits.MaxGroupDepth = maxGroupDepth
+	}
+	return &limiter{Limits: limits}
+}
+
+// consumeField is consumeField with the limits checked, depth is the nesting depth of the
+// message pb belongs to.
+func (l *limiter) consumeField(pb []byte, field *Result, depth int) (protowire.Number, int, error) {
+	if l == nil {
+		return consumeField(pb, field)
+	}
+	if l.MaxDepth > 0 && depth > l.MaxDepth {
+		return 0, 0, ErrLimitExceeded
+	}
+	if err := l.countField(); err != nil {
+		return 0, 0, err
+	}
+	fieldNumber, n, err := l.consumeFieldDepth(pb, field, l.MaxGroupDepth)
+	if err != nil {
+		return fieldNumber, n, err
+	}
+	l.bytes += n
+	if l.MaxBytes > 0 && l.bytes > l.MaxBytes {
+		return fieldNumber, 0, ErrLimitExceeded
+	}
+	return fieldNumber, n, nil
+}
+
+// countField counts a field read, ErrLimitExceeded is returned if there are too many.
+func (l *limiter) countField() error {
+	if l == nil {
+		return nil
+	}
+	l.fields++
+	if l.MaxFields > 0 && l.fields > l.MaxFields {
+		return ErrLimitExceeded
+	}
+	return nil
+}
+
+// group returns the lengths of the group cached, pb is the group body following the start tag.
+func (l *limiter) group(pb []byte) (groupSpan, bool) {
+	if l == nil || l.groups == nil || len(pb) == 0 {
+		return groupSpan{}, false
+	}
+	span, ok := l.groups[&pb[0]]
+	return span, ok
+}
+
+// cacheGroup caches the lengths of the group consumed with groupDepth more levels allowed. Only
+// the nested groups are cached, as the outermost ones are not consumed again.
+func (l *limiter) cacheGroup(pb []byte, groupDepth int, span groupSpan) {
+	if l == nil || !l.cacheGroups || groupDepth >= l.MaxGroupDepth {
+		return
+	}
+	if l.groups == nil {
+		l.groups = make(map[*byte]groupSpan)
+	}
+	l.groups[&pb[0]] = span
+}
+
+// ignorable reports whether the error of a length-delimited field matched by a wildcard can be
+// ignored, as the field is not necessarily a message. Exceeding the limits is never ignored.
+func ignorable(err error) bool {
+	return err != nil && !errors.Is(err, ErrLimitExceeded)
+}
+
+// GetOneE is GetOneE with the options applied.
+func (o Options) GetOneE(pb []byte, pbNumbers ...protowire.Number) (result Result, err error) {
+	result.WireType = InvalidWireType
+	err = Result{Raw: pb}.getIter(newLimiter(o.Limits), func(r Result) bool {
+		result = r
+		return false
+	}, pbNumbers...)
+	if err != nil {
+		result = Result{WireType: InvalidWireType}
+	}
+	return
+}
+
+// GetAllE is GetAllE with the options applied.
+func (o Options) GetAllE(pb []byte, pbNumbers ...protowire.Number) ([]Result, error) {
+	results := make([]Result, 0)
+	err := o.GetIter(pb, func(r Result) bool {
+		results = append(results, r)
+		return true
+	}, pbNumbers...)
+	return results, err
+}
+
+// GetIter is Result.GetIter with the options applied.
+func (o Options) GetIter(pb []byte, resultSink func(Result) bool, pbNumbers ...protowire.Number) error {
+	return Result{Raw: pb}.getIter(newLimiter(o.Limits), resultSink, pbNumbers...)
+}
+
+// IterFields is Result.IterFields with the options applied.
+func (o Options) IterFields(pb []byte, pbNumber protowire.Number, resultSink func(r Result) bool) (int, error) {
+	return Result{Raw: pb}.iterFields(newLimiter(o.Limits), 0, pbNumber, resultSink)
+}
+
+// ForEach is ForEach with the options applied.
+func (o Options) ForEach(pb []byte, iterator func(num protowire.Number, f Result) bool) error {
+	return Result{Raw: pb}.forEach(newLimiter(o.Limits), iterator)
+}
+
+// ForEachPath is ForEachPath with the options applied.
+func (o Options) ForEachPath(pb []byte, path string, iterator func(Result) bool) error {
+	var buf [inlinePathSegments]pathSegment
+	p, err := parsePath(path, buf[:0], resolveFieldNumber)
+	if err != nil {
+		return err
+	}
+	return Result{Raw: pb}.walkPath(p, newLimiter(o.Limits), iterator)
+}
+
+// Walk is Walk with the options applied.
+func (o Options) Walk(pb []byte, visitor Visitor) error {
+	return Result{Raw: pb}.walk(newLimiter(o.Limits), visitor)
+}
+
+// WithOptions returns a copy of the query with the options applied.
+func (q *Query) WithOptions(o Options) *Query {
+	c := *q
+	c.limits = o.Limits
+	return &c
+}
+
+// WithOptions returns a copy of the multi-query with the options applied.
+func (m *MultiQuery) WithOptions(o Options) *MultiQuery {
+	c := *m
+	c.limits = o.Limits
+	return &c
+}
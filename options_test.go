@@ -0,0 +1,155 @@
+package gpb
+
+import (
+	"testing"
+
+	"github.com/ywx217/gpb/protoscope"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// nestedMessage returns a message with field 1 nested in field 1 for the given levels, the
+// innermost one is a string.
+func nestedMessage(levels int) []byte {
+	pb := protowire.AppendTag(nil, 1, protowire.BytesType)
+	pb = protowire.AppendString(pb, "x")
+	for i := 1; i < levels; i++ {
+		outer := protowire.AppendTag(nil, 1, protowire.BytesType)
+		pb = protowire.AppendBytes(outer, pb)
+	}
+	return pb
+}
+
+func TestOptionsDefault(t *testing.T) {
+	bs := marshalRepeatedGoTest(t)
+	var opts Options
+
+	expected, err := GetAllE(bs, 5, 1)
+	require.NoError(t, err)
+	results, err := opts.GetAllE(bs, 5, 1)
+	require.NoError(t, err)
+	require.Equal(t, expected, results)
+
+	r, err := opts.GetOneE(bs, 4, 1)
+	require.NoError(t, err)
+	require.Equal(t, "label", r.String())
+
+	n, err := opts.IterFields(bs, 1, func(Result) bool { return true })
+	require.NoError(t, err)
+	require.Equal(t, len(bs), n)
+
+	var fields int
+	require.NoError(t, opts.ForEach(bs, func(protowire.Number, Result) bool {
+		fields++
+		return true
+	}))
+	require.NotZero(t, fields)
+	_, err = Options{Limits: Limits{MaxFields: fields - 1}}.IterFields(bs, 0, func(Result) bool { return true })
+	require.ErrorIs(t, err, ErrLimitExceeded)
+
+	var values []string
+	require.NoError(t, opts.ForEachPath(bs, "5.#1:.1", func(r Result) bool {
+		values = append(values, r.String())
+		return true
+	}))
+	require.Equal(t, []string{"l1", "l2"}, values)
+}
+
+func TestLimitsFields(t *testing.T) {
+	bs := marshalRepeatedGoTest(t)
+	opts := Options{Limits: Limits{MaxFields: 3}}
+
+	r, err := opts.GetOneE(bs, 1)
+	require.NoError(t, err, "the first field is found before the limit")
+	require.True(t, r.Exist())
+
+	_, err = opts.GetAllE(bs, 1)
+	require.ErrorIs(t, err, ErrLimitExceeded)
+	pe := requireParseError(t, err)
+	_, n, _ := consumeField(bs, &r)
+	_, m, _ := consumeField(bs[n:], &r)
+	_, k, _ := consumeField(bs[n+m:], &r)
+	require.Equal(t, n+m+k, pe.Offset, "the 4th field is not read")
+
+	_, err = opts.IterFields(bs, 1, func(Result) bool { return true })
+	require.ErrorIs(t, err, ErrLimitExceeded)
+
+	// the fields inside the groups skipped are counted
+	pb := protoscope.MustParse(`1: 1 2: !{3: 3 4: 4} 5: 5`)
+	_, err = Options{Limits: Limits{MaxFields: 6}}.GetAllE(pb, 5)
+	require.NoError(t, err)
+	_, err = Options{Limits: Limits{MaxFields: 5}}.GetAllE(pb, 5)
+	require.ErrorIs(t, err, ErrLimitExceeded)
+	require.Equal(t, 8, requireParseError(t, err).Offset)
+	_, err = Options{Limits: Limits{MaxFields: 4}}.GetAllE(pb, 5)
+	require.ErrorIs(t, err, ErrLimitExceeded)
+	pe = requireParseError(t, err)
+	require.Equal(t, 7, pe.Offset, "the end group tag is not read")
+	require.Equal(t, []protowire.Number{2}, pe.Path)
+}
+
+func TestLimitsBytes(t *testing.T) {
+	bs := marshalRepeatedGoTest(t)
+	results, err := Options{Limits: Limits{MaxBytes: len(bs)}}.GetAllE(bs, 1)
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+
+	_, err = Options{Limits: Limits{MaxBytes: len(bs) - 1}}.GetAllE(bs, 1)
+	require.ErrorIs(t, err, ErrLimitExceeded)
+
+	// the embedded messages descended into are counted again
+	_, err = Options{Limits: Limits{MaxBytes: len(bs)}}.GetAllE(bs, 5, 1)
+	require.ErrorIs(t, err, ErrLimitExceeded)
+}
+
+func TestLimitsDepth(t *testing.T) {
+	pb := nestedMessage(3)
+	opts := Options{Limits: Limits{MaxDepth: 1}}
+
+	r, err := opts.GetOneE(pb, 1, 1)
+	require.NoError(t, err)
+	require.True(t, r.Exist())
+
+	_, err = opts.GetOneE(pb, 1, 1, 1)
+	require.ErrorIs(t, err, ErrLimitExceeded)
+	pe := requireParseError(t, err)
+	require.Equal(t, []protowire.Number{1, 1}, pe.Path)
+	require.Equal(t, 4, pe.Offset)
+
+	// exceeding the limits is not ignored by wildcards
+	err = opts.ForEachPath(pb, "*.*.1", func(Result) bool { return true })
+	require.ErrorIs(t, err, ErrLimitExceeded)
+	require.Equal(t, pe.Error(), err.Error())
+	require.NoError(t, ForEachPath(pb, "*.*.1", func(Result) bool { return true }))
+
+	q := MustCompile("1.1.1")
+	require.Equal(t, "x", q.GetOne(pb).String())
+	require.False(t, q.WithOptions(opts).GetOne(pb).Exist())
+	require.Equal(t, "x", q.GetOne(pb).String(), "the original query is not changed")
+
+	m := MustCompileMany("1.1", "*.*.1")
+	results := make([]Result, m.Len())
+	require.NoError(t, m.GetMany(pb, results))
+	err = m.WithOptions(opts).GetMany(pb, results)
+	require.ErrorIs(t, err, ErrLimitExceeded)
+	require.Equal(t, pe.Error(), err.Error())
+}
+
+func TestLimitsGroupDepth(t *testing.T) {
+	var pb []byte
+	for i := 0; i < 3; i++ {
+		pb = protowire.AppendTag(pb, 1, protowire.StartGroupType)
+	}
+	for i := 0; i < 3; i++ {
+		pb = protowire.AppendTag(pb, 1, protowire.EndGroupType)
+	}
+
+	_, err := Options{Limits: Limits{MaxGroupDepth: 3}}.GetAllE(pb, 1)
+	require.NoError(t, err)
+	_, err = Options{Limits: Limits{MaxGroupDepth: 2}}.GetAllE(pb, 1)
+	require.ErrorIs(t, err, ErrLimitExceeded)
+	pe := requireParseError(t, err)
+	require.Equal(t, 2, pe.Offset)
+	require.Equal(t, []protowire.Number{1, 1}, pe.Path)
+}
@@ -0,0 +1,219 @@
+package gpb
+
+import (
+	"encoding/binary"
+	"math"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// IterVarint calls fn with the varints of the packed frame until fn returns false, without any
+// allocation. A varint result itself is also accepted as a frame of a single element, so both
+// packed and unpacked occurrences of a repeated field can be read by the same code. Malformed
+// bytes at the end of the frame are ignored like UnpackVarint.
+func (r Result) IterVarint(fn func(v uint64) bool) {
+	switch r.WireType {
+	case protowire.VarintType:
+		fn(r.Varint)
+	case protowire.BytesType:
+		for pb := r.Raw; len(pb) > 0; {
+			if pb[0] < 0x80 {
+				// one byte varints are the most common in packed frames
+				if !fn(uint64(pb[0])) {
+					return
+				}
+				pb = pb[1:]
+				continue
+			}
+			v, n := protowire.ConsumeVarint(pb)
+			if n < 0 || !fn(v) {
+				return
+			}
+			pb = pb[n:]
+		}
+	}
+}
+
+// IterFixed32 calls fn with the fixed32 values of the packed frame until fn returns false,
+// without any allocation. A fixed32 result itself is also accepted as a frame of a single
+// element.
+func (r Result) IterFixed32(fn func(v uint32) bool) {
+	switch r.WireType {
+	case protowire.Fixed32Type:
+		fn(r.Fixed32())
+	case protowire.BytesType:
+		for pb := r.Raw; len(pb) >= 4; pb = pb[4:] {
+			if !fn(binary.LittleEndian.Uint32(pb)) {
+				return
+			}
+		}
+	}
+}
+
+// IterFixed64 calls fn with the fixed64 values of the packed frame until fn returns false,
+// without any allocation. A fixed64 result itself is also accepted as a frame of a single
+// element.
+func (r Result) IterFixed64(fn func(v uint64) bool) {
+	switch r.WireType {
+	case protowire.Fixed64Type:
+		fn(r.Fixed64())
+	case protowire.BytesType:
+		for pb := r.Raw; len(pb) >= 8; pb = pb[8:] {
+			if !fn(binary.LittleEndian.Uint64(pb)) {
+				return
+			}
+		}
+	}
+}
+
+// AppendInt32s appends the int32 elements of the packed frame, or the varint result, to dst.
+// The Append functions decode the values straight into dst, and grow it at most once.
+func (r Result) AppendInt32s(dst []int32) []int32 {
+	dst = grow(dst, r.countVarints())
+	r.IterVarint(func(v uint64) bool {
+		dst = append(dst, int32(v))
+		return true
+	})
+	return dst
+}
+
+func (r Result) AppendInt64s(dst []int64) []int64 {
+	dst = grow(dst, r.countVarints())
+	r.IterVarint(func(v uint64) bool {
+		dst = append(dst, int64(v))
+		return true
+	})
+	return dst
+}
+
+func (r Result) AppendUint32s(dst []uint32) []uint32 {
+	dst = grow(dst, r.countVarints())
+	r.IterVarint(func(v uint64) bool {
+		dst = append(dst, uint32(v))
+		return true
+	})
+	return dst
+}
+
+func (r Result) AppendUint64s(dst []uint64) []uint64 {
+	dst = grow(dst, r.countVarints())
+	r.IterVarint(func(v uint64) bool {
+		dst = append(dst, v)
+		return true
+	})
+	return dst
+}
+
+func (r Result) AppendSint32s(dst []int32) []int32 {
+	dst = grow(dst, r.countVarints())
+	r.IterVarint(func(v uint64) bool {
+		dst = append(dst, int32(protowire.DecodeZigZag(v)))
+		return true
+	})
+	return dst
+}
+
+func (r Result) AppendSint64s(dst []int64) []int64 {
+	dst = grow(dst, r.countVarints())
+	r.IterVarint(func(v uint64) bool {
+		dst = append(dst, protowire.DecodeZigZag(v))
+		return true
+	})
+	return dst
+}
+
+func (r Result) AppendBools(dst []bool) []bool {
+	dst = grow(dst, r.countVarints())
+	r.IterVarint(func(v uint64) bool {
+		dst = append(dst, protowire.DecodeBool(v))
+		return true
+	})
+	return dst
+}
+
+func (r Result) AppendFixed32s(dst []uint32) []uint32 {
+	dst = grow(dst, r.countFixed(4))
+	r.IterFixed32(func(v uint32) bool {
+		dst = append(dst, v)
+		return true
+	})
+	return dst
+}
+
+func (r Result) AppendSFixed32s(dst []int32) []int32 {
+	dst = grow(dst, r.countFixed(4))
+	r.IterFixed32(func(v uint32) bool {
+		dst = append(dst, int32(v))
+		return true
+	})
+	return dst
+}
+
+func (r Result) AppendFloat32s(dst []float32) []float32 {
+	dst = grow(dst, r.countFixed(4))
+	r.IterFixed32(func(v uint32) bool {
+		dst = append(dst, math.Float32frombits(v))
+		return true
+	})
+	return dst
+}
+
+func (r Result) AppendFixed64s(dst []uint64) []uint64 {
+	dst = grow(dst, r.countFixed(8))
+	r.IterFixed64(func(v uint64) bool {
+		dst = append(dst, v)
+		return true
+	})
+	return dst
+}
+
+func (r Result) AppendSFixed64s(dst []int64) []int64 {
+	dst = grow(dst, r.countFixed(8))
+	r.IterFixed64(func(v uint64) bool {
+		dst = append(dst, int64(v))
+		return true
+	})
+	return dst
+}
+
+func (r Result) AppendFloat64s(dst []float64) []float64 {
+	dst = grow(dst, r.countFixed(8))
+	r.IterFixed64(func(v uint64) bool {
+		dst = append(dst, math.Float64frombits(v))
+		return true
+	})
+	return dst
+}
+
+// countVarints returns the number of varints in the packed frame, which is the number of the
+// bytes without the continuation bit.
+func (r Result) countVarints() int {
+	if r.WireType != protowire.BytesType {
+		return 1
+	}
+	n := 0
+	for _, b := range r.Raw {
+		if b < 0x80 {
+			n++
+		}
+	}
+	return n
+}
+
+// countFixed returns the number of the fixed-size elements in the packed frame.
+func (r Result) countFixed(size int) int {
+	if r.WireType != protowire.BytesType {
+		return 1
+	}
+	return len(r.Raw) / size
+}
+
+// grow makes room for n more elements in s.
+func grow[T any](s []T, n int) []T {
+	if cap(s)-len(s) >= n {
+		return s
+	}
+	grown := make([]T, len(s), len(s)+n)
+	copy(grown, s)
+	return grown
+}
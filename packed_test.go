@@ -0,0 +1,157 @@
+package gpb
+
+import (
+	"testing"
+
+	"github.com/ywx217/gpb/protoscope"
+
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestAppendPacked(t *testing.T) {
+	msg := initGoTest(false)
+	msg.F_BoolRepeatedPacked = []bool{true, false, true}
+	msg.F_Int32RepeatedPacked = []int32{-1, 0, 300}
+	msg.F_Int64RepeatedPacked = []int64{-64, 1 << 40}
+	msg.F_Fixed32RepeatedPacked = []uint32{32, 1 << 31}
+	msg.F_Fixed64RepeatedPacked = []uint64{64, 1 << 63}
+	msg.F_Uint32RepeatedPacked = []uint32{1 << 31}
+	msg.F_Uint64RepeatedPacked = []uint64{1 << 63, 1}
+	msg.F_FloatRepeatedPacked = []float32{1.5, -2}
+	msg.F_DoubleRepeatedPacked = []float64{-1.5, 2}
+	msg.F_Sint32RepeatedPacked = []int32{-32, 32, -1 << 31}
+	msg.F_Sint64RepeatedPacked = []int64{-64, 64}
+	msg.F_Sfixed32RepeatedPacked = []int32{-32, 32}
+	msg.F_Sfixed64RepeatedPacked = []int64{-64, 64}
+	bs, err := proto.Marshal(msg)
+	require.NoError(t, err)
+
+	require.Equal(t, msg.F_BoolRepeatedPacked, GetOne(bs, 50).AppendBools(nil))
+	require.Equal(t, msg.F_Int32RepeatedPacked, GetOne(bs, 51).AppendInt32s(nil))
+	require.Equal(t, msg.F_Int64RepeatedPacked, GetOne(bs, 52).AppendInt64s(nil))
+	require.Equal(t, msg.F_Fixed32RepeatedPacked, GetOne(bs, 53).AppendFixed32s(nil))
+	require.Equal(t, msg.F_Fixed64RepeatedPacked, GetOne(bs, 54).AppendFixed64s(nil))
+	require.Equal(t, msg.F_Uint32RepeatedPacked, GetOne(bs, 55).AppendUint32s(nil))
+	require.Equal(t, msg.F_Uint64RepeatedPacked, GetOne(bs, 56).AppendUint64s(nil))
+	require.Equal(t, msg.F_FloatRepeatedPacked, GetOne(bs, 57).AppendFloat32s(nil))
+	require.Equal(t, msg.F_DoubleRepeatedPacked, GetOne(bs, 58).AppendFloat64s(nil))
+	require.Equal(t, msg.F_Sint32RepeatedPacked, GetOne(bs, 502).AppendSint32s(nil))
+	require.Equal(t, msg.F_Sint64RepeatedPacked, GetOne(bs, 503).AppendSint64s(nil))
+	require.Equal(t, msg.F_Sfixed32RepeatedPacked, GetOne(bs, 504).AppendSFixed32s(nil))
+	require.Equal(t, msg.F_Sfixed64RepeatedPacked, GetOne(bs, 505).AppendSFixed64s(nil))
+
+	// the same values as the unpacked results
+	require.Equal(t, lo.Map(GetOne(bs, 502).UnpackVarint(), func(r Result, _ int) int32 { return r.Sint32() }),
+		GetOne(bs, 502).AppendSint32s(nil))
+	require.Equal(t, lo.Map(GetOne(bs, 57).UnpackFixed32(), func(r Result, _ int) float32 { return r.Float32() }),
+		GetOne(bs, 57).AppendFloat32s(nil))
+}
+
+func TestAppendPackedMixed(t *testing.T) {
+	// unpacked occurrences are single element frames, other wire types are ignored
+	var values []int64
+	for _, r := range GetAll(protoscope.MustParse(`1: 1 1: {2 3} 1: 4i32 1: 5`), 1) {
+		values = r.AppendInt64s(values)
+	}
+	require.Equal(t, []int64{1, 2, 3, 5}, values)
+
+	var fixed []uint32
+	for _, r := range GetAll(protoscope.MustParse(`1: 1i32 1: {2i32 3i32} 1: 4`), 1) {
+		fixed = r.AppendFixed32s(fixed)
+	}
+	require.Equal(t, []uint32{1, 2, 3}, fixed)
+
+	// appended after the existing elements, malformed bytes at the end are ignored
+	require.Equal(t, []uint64{7, 1, 2}, GetOne(protoscope.MustParse(`1: {1 2 `+"`80`"+`}`), 1).AppendUint64s([]uint64{7}))
+	require.Equal(t, []float64{1}, GetOne(protoscope.MustParse(`1: {1.0i64 `+"`00`"+`}`), 1).AppendFloat64s(nil))
+	require.Empty(t, Result{WireType: InvalidWireType}.AppendInt32s(nil))
+}
+
+func TestIterPacked(t *testing.T) {
+	r := GetOne(protoscope.MustParse(`1: {1 300 3}`), 1)
+	var values []uint64
+	r.IterVarint(func(v uint64) bool {
+		values = append(values, v)
+		return v < 300
+	})
+	require.Equal(t, []uint64{1, 300}, values)
+
+	var sum uint64
+	require.Zero(t, testing.AllocsPerRun(100, func() {
+		r.IterVarint(func(v uint64) bool {
+			sum += v
+			return true
+		})
+	}))
+
+	dst := make([]int64, 0, 3)
+	require.Zero(t, testing.AllocsPerRun(100, func() {
+		dst = r.AppendInt64s(dst[:0])
+	}))
+	require.Equal(t, []int64{1, 300, 3}, dst)
+}
+
+// packed int64s of 100k elements
+func benchmarkPacked(b *testing.B, run func(b *testing.B, r Result)) {
+	values := make([]int64, 100000)
+	for i := range values {
+		values[i] = int64(i * i)
+	}
+	msg := initGoTest(false)
+	msg.F_Int64RepeatedPacked = values
+	bs, err := proto.Marshal(msg)
+	require.NoError(b, err)
+	r := GetOne(bs, 52)
+	b.ReportAllocs()
+	b.ResetTimer()
+	run(b, r)
+}
+
+func BenchmarkUnpackVarint(b *testing.B) {
+	benchmarkPacked(b, func(b *testing.B, r Result) {
+		var sum int64
+		for i := 0; i < b.N; i++ {
+			for _, item := range r.UnpackVarint() {
+				sum += item.Int64()
+			}
+		}
+	})
+}
+
+func BenchmarkAppendInt64s(b *testing.B) {
+	benchmarkPacked(b, func(b *testing.B, r Result) {
+		var sum int64
+		for i := 0; i < b.N; i++ {
+			for _, v := range r.AppendInt64s(nil) {
+				sum += v
+			}
+		}
+	})
+}
+
+func BenchmarkAppendInt64sReused(b *testing.B) {
+	benchmarkPacked(b, func(b *testing.B, r Result) {
+		var sum int64
+		var values []int64
+		for i := 0; i < b.N; i++ {
+			values = r.AppendInt64s(values[:0])
+			for _, v := range values {
+				sum += v
+			}
+		}
+	})
+}
+
+func BenchmarkIterVarint(b *testing.B) {
+	benchmarkPacked(b, func(b *testing.B, r Result) {
+		var sum int64
+		for i := 0; i < b.N; i++ {
+			r.IterVarint(func(v uint64) bool {
+				sum += int64(v)
+				return true
+			})
+		}
+	})
+}
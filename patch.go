@@ -0,0 +1,503 @@
+package gpb
+
+import (
+	"github.com/pkg/errors"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// inlinePatchFields the number of fields edited in a message without allocations
+const inlinePatchFields = 16
+
+// Patch queues edits of a message by paths, and applies them in a single pass, e.g.
+//
+//	pb, err := gpb.NewPatch().
+//		Set(gpb.ValueOfString("label"), 4, 1).
+//		Delete(5).
+//		Append(gpb.ValueOfInt32(35), 21).
+//		Apply(pb)
+//
+// Edits are applied in the order queued, and a later edit overrides the earlier ones of the
+// same field. Edits into a message apply to all its occurrences, which are merged into one
+// message when decoded: the fields are deleted or cleared in every occurrence, and the values are
+// written into the last one only, so that a single value is left. So the edits by field numbers
+// into a repeated message apply to all its elements, use SetPath and the like with the selectors
+// to edit some of them, e.g. `5.#1.1`. Edits into a message set before apply to the value, and the
+// missing messages are created unless only fields are deleted or cleared in them. Only the fields
+// edited are rewritten, along with the length prefixes of the messages enclosing them, which are
+// computed once for all the edits.
+//
+// The zero value is an empty patch. A patch can be applied to many messages, but it must not be
+// modified concurrently.
+type Patch struct {
+	root patchNode
+	err  error
+}
+
+// patchNode holds the edits of a message.
+type patchNode struct {
+	// clear the fields of the original message are removed
+	clear bool
+	// creates whether any value is written in the message, which is created if missing
+	creates bool
+	fields  map[protowire.Number]*fieldPatch
+	// order the field numbers in the order edited first
+	order []protowire.Number
+}
+
+// fieldPatch holds the edits of a field in a message.
+type fieldPatch struct {
+	// index the index of the field in the order
+	index int
+	// replace the occurrences in the original message are removed, and the value is written at
+	// the position of the first one if hasValue
+	replace  bool
+	hasValue bool
+	value    Value
+	// child the edits into the occurrences, or into the value
+	child *patchNode
+	// selected the edits into the occurrences selected by the path segments, which are applied
+	// after the ones of child
+	selected []selectedPatch
+	appends  []Value
+}
+
+// selectedPatch holds the edits into the occurrences of a message selected by a path segment.
+type selectedPatch struct {
+	seg  pathSegment
+	node *patchNode
+}
+
+// NewPatch creates an empty patch.
+func NewPatch() *Patch {
+	return &Patch{}
+}
+
+// Set replaces all the occurrences of the field at the path with the value.
+func (p *Patch) Set(value Value, pbNumbers ...protowire.Number) *Patch {
+	if f := p.field(pbNumbers, true, value.check()); f != nil {
+		*f = fieldPatch{index: f.index, replace: true, hasValue: true, value: value}
+	}
+	return p
+}
+
+// Delete removes all the occurrences of the field at the path.
+func (p *Patch) Delete(pbNumbers ...protowire.Number) *Patch {
+	if f := p.field(pbNumbers, false, nil); f != nil {
+		*f = fieldPatch{index: f.index, replace: true}
+	}
+	return p
+}
+
+// Append appends an occurrence of the field at the path after the existing ones.
+func (p *Patch) Append(value Value, pbNumbers ...protowire.Number) *Patch {
+	if f := p.field(pbNumbers, true, value.check()); f != nil {
+		f.appends = append(f.appends, value)
+	}
+	return p
+}
+
+// Clear removes all the fields of the message at the path, the message itself is kept. An empty
+// path clears the root message.
+func (p *Patch) Clear(pbNumbers ...protowire.Number) *Patch {
+	if p.err != nil {
+		return p
+	}
+	n := p.node(numberSegments(pbNumbers), false)
+	*n = patchNode{clear: true, creates: n.creates}
+	return p
+}
+
+// SetPath is Set of the field at the path in the path syntax, where the occurrences of the
+// messages along the path can be selected, e.g. `5.#1.1` is field 1 of the second message in
+// repeated field 5, and the other messages are left as-is. The values are written into every
+// occurrence selected, and nothing is edited if it is missing. Wildcards and `#` are not allowed,
+// and the field edited can not be selected.
+func (p *Patch) SetPath(value Value, path string) *Patch {
+	if f := p.fieldPath(path, true, value.check()); f != nil {
+		*f = fieldPatch{index: f.index, replace: true, hasValue: true, value: value}
+	}
+	return p
+}
+
+// DeletePath is Delete of the field at the path in the path syntax, see SetPath for the paths.
+func (p *Patch) DeletePath(path string) *Patch {
+	if f := p.fieldPath(path, false, nil); f != nil {
+		*f = fieldPatch{index: f.index, replace: true}
+	}
+	return p
+}
+
+// AppendPath is Append of the field at the path in the path syntax, see SetPath for the paths.
+func (p *Patch) AppendPath(value Value, path string) *Patch {
+	if f := p.fieldPath(path, true, value.check()); f != nil {
+		f.appends = append(f.appends, value)
+	}
+	return p
+}
+
+// ClearPath is Clear of the message at the path in the path syntax, see SetPath for the paths.
+// Unlike the fields edited, the message cleared can be selected, e.g. `5.#1`.
+func (p *Patch) ClearPath(path string) *Patch {
+	if p.err != nil {
+		return p
+	}
+	segments, err := parsePatchPath(path)
+	if err != nil {
+		p.err = err
+		return p
+	}
+	n := p.node(segments, false)
+	*n = patchNode{clear: true, creates: n.creates}
+	return p
+}
+
+// Err returns the first error of the edits queued, e.g. an empty path.
+func (p *Patch) Err() error {
+	return p.err
+}
+
+// Apply applies the edits to the message, and returns the new message.
+func (p *Patch) Apply(pb []byte) ([]byte, error) {
+	return p.ApplyInto(nil, pb)
+}
+
+// ApplyInto is Apply, but the new message is appended to dst, which must not overlap pb. The
+// ParseErrors of a message value edited into are wrapped with its field number, and their offsets
+// are in the bytes of the value instead of pb.
+func (p *Patch) ApplyInto(dst, pb []byte) ([]byte, error) {
+	if p.err != nil {
+		return dst, p.err
+	}
+	out, err := p.root.apply(dst, pb, true)
+	if err != nil {
+		return dst, locate(err, Result{Raw: pb})
+	}
+	return out, nil
+}
+
+// field returns the edits of the field at the path, nil is returned if the edit is invalid.
+func (p *Patch) field(pbNumbers []protowire.Number, creates bool, err error) *fieldPatch {
+	if err == nil && len(pbNumbers) == 0 {
+		err = errors.WithMessage(ErrInvalidPath, "empty path")
+	}
+	return p.fieldAt(numberSegments(pbNumbers), creates, err)
+}
+
+// fieldPath is field of the path in the path syntax.
+func (p *Patch) fieldPath(path string, creates bool, err error) *fieldPatch {
+	segments, pathErr := parsePatchPath(path)
+	if err == nil {
+		err = pathErr
+	}
+	if err == nil && segments[len(segments)-1].selector != selectAll {
+		err = errors.WithMessagef(ErrInvalidPath, "the field edited can not be selected, path=%q", path)
+	}
+	return p.fieldAt(segments, creates, err)
+}
+
+// fieldAt returns the edits of the field at the path segments, nil is returned if the edit is
+// invalid.
+func (p *Patch) fieldAt(segments []pathSegment, creates bool, err error) *fieldPatch {
+	if p.err == nil {
+		p.err = err
+	}
+	if p.err != nil {
+		return nil
+	}
+	last := len(segments) - 1
+	return p.node(segments[:last], creates).field(segments[last].number)
+}
+
+// node returns the edits of the message at the path, the messages along the path are marked to
+// be created if creates.
+func (p *Patch) node(path []pathSegment, creates bool) *patchNode {
+	n := &p.root
+	n.creates = n.creates || creates
+	for _, seg := range path {
+		child := n.field(seg.number).childOf(seg)
+		if *child == nil {
+			*child = &patchNode{}
+		}
+		n = *child
+		n.creates = n.creates || creates
+	}
+	return n
+}
+
+// childOf returns the edits into the occurrences selected by the segment.
+func (f *fieldPatch) childOf(seg pathSegment) **patchNode {
+	if seg.selector == selectAll {
+		return &f.child
+	}
+	for i := range f.selected {
+		if f.selected[i].seg == seg {
+			return &f.selected[i].node
+		}
+	}
+	f.selected = append(f.selected, selectedPatch{seg: seg})
+	return &f.selected[len(f.selected)-1].node
+}
+
+// numberSegments converts the field numbers into the path segments selecting all the occurrences.
+func numberSegments(pbNumbers []protowire.Number) []pathSegment {
+	segments := make([]pathSegment, len(pbNumbers))
+	for i, number := range pbNumbers {
+		segments[i].number = number
+	}
+	return segments
+}
+
+// parsePatchPath parses the path of the edits, where wildcards and `#` are not allowed.
+func parsePatchPath(path string) ([]pathSegment, error) {
+	p, err := parsePath(path, nil, resolveFieldNumber)
+	if err != nil {
+		return nil, err
+	}
+	if p.count {
+		return nil, errors.WithMessagef(ErrInvalidPath, "`#` can not be edited, path=%q", path)
+	}
+	for _, seg := range p.segments {
+		if seg.number == anyNumber {
+			return nil, errors.WithMessagef(ErrInvalidPath, "wildcard can not be edited, path=%q", path)
+		}
+	}
+	return p.segments, nil
+}
+
+func (n *patchNode) field(number protowire.Number) *fieldPatch {
+	if f, ok := n.fields[number]; ok {
+		return f
+	}
+	if n.fields == nil {
+		n.fields = make(map[protowire.Number]*fieldPatch)
+	}
+	f := &fieldPatch{index: len(n.order)}
+	n.fields[number] = f
+	n.order = append(n.order, number)
+	return f
+}
+
+// apply appends msg to dst with the edits applied, the values are written only if write, as msg
+// is not the last occurrence of the message otherwise.
+func (n *patchNode) apply(dst, msg []byte, write bool) ([]byte, error) {
+	if n.clear {
+		msg = nil
+	}
+	// written tells whether the field edited is written
+	var buf [inlinePatchFields]bool
+	written := buf[:]
+	if len(n.order) > len(buf) {
+		written = make([]bool, len(n.order))
+	}
+	// lastStarts the start of the last occurrence of the message edited, where the values are
+	// written into
+	var startBuf [inlinePatchFields]int
+	lastStarts := startBuf[:]
+	if len(n.order) > len(startBuf) {
+		lastStarts = make([]int, len(n.order))
+	}
+	// occurrences the number of the occurrences of the message edited read
+	var occurrenceBuf [inlinePatchFields]int
+	occurrences := occurrenceBuf[:]
+	if len(n.order) > len(occurrenceBuf) {
+		occurrences = make([]int, len(n.order))
+	}
+	if n.findsLast(write) {
+		if err := n.findLast(lastStarts, msg); err != nil {
+			return dst, err
+		}
+	}
+
+	var err error
+	last := 0
+	_, iterErr := Result{Raw: msg}.IterFields(anyNumber, func(field Result) bool {
+		f := n.fields[field.Number]
+		if f == nil || field.WireType == protowire.EndGroupType {
+			return true
+		}
+		dst = append(dst, msg[last:field.Start]...)
+		last = field.End
+		switch {
+		case f.replace:
+			if write && f.hasValue && !written[f.index] {
+				written[f.index] = true
+				dst, err = f.appendValue(dst, field.Number)
+			}
+		case f.child != nil || f.selected != nil:
+			last := field.Start == lastStarts[f.index]
+			into := write && f.child != nil && f.child.creates && last
+			written[f.index] = written[f.index] || into
+			dst, err = f.applyField(dst, msg, field, occurrences[f.index], last, into)
+			occurrences[f.index]++
+		default:
+			dst = append(dst, msg[field.Start:field.End]...)
+		}
+		return err == nil
+	})
+	if iterErr != nil {
+		return dst, iterErr
+	} else if err != nil {
+		return dst, err
+	}
+	dst = append(dst, msg[last:]...)
+	if !write {
+		return dst, nil
+	}
+
+	for _, number := range n.order {
+		f := n.fields[number]
+		if !written[f.index] {
+			if f.hasValue {
+				dst, err = f.appendValue(dst, number)
+			} else if f.child != nil && f.child.creates {
+				dst = protowire.AppendTag(dst, number, protowire.BytesType)
+				dst, err = appendLengthPrefixed(dst, func(dst []byte) ([]byte, error) {
+					return f.child.apply(dst, nil, true)
+				})
+				err = enclose(err, number)
+			}
+			if err != nil {
+				return dst, err
+			}
+		}
+		for _, v := range f.appends {
+			dst = v.appendField(dst, number)
+		}
+	}
+	return dst, nil
+}
+
+// findLast fills starts with the start of the last occurrence of the fields edited, indexed by the
+// order of the fields.
+func (n *patchNode) findLast(starts []int, msg []byte) error {
+	_, err := Result{Raw: msg}.IterFields(anyNumber, func(field Result) bool {
+		if f := n.fields[field.Number]; f != nil && field.WireType != protowire.EndGroupType {
+			starts[f.index] = field.Start
+		}
+		return true
+	})
+	return err
+}
+
+// findsLast tells whether the last occurrences of the messages edited are needed, which the
+// values are written into if write, or which are selected by `#last`.
+func (n *patchNode) findsLast(write bool) bool {
+	for _, f := range n.fields {
+		if f.replace {
+			continue
+		}
+		if write && f.child != nil && f.child.creates {
+			return true
+		}
+		for _, s := range f.selected {
+			if s.seg.selector == selectLast {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// applyField appends the occurrence of the message edited to dst with the edits applied to its
+// content, see applyContent for the arguments.
+func (f *fieldPatch) applyField(dst, msg []byte, field Result, occurrence int, last, write bool) ([]byte, error) {
+	var err error
+	switch field.WireType {
+	case protowire.BytesType:
+		// the length prefix is written again
+		dst = append(dst, msg[field.Start:field.Start+len(field.Tag(msg))]...)
+		dst, err = appendLengthPrefixed(dst, func(dst []byte) ([]byte, error) {
+			return f.applyContent(dst, field.Raw, occurrence, last, write)
+		})
+	case protowire.StartGroupType:
+		rawEnd := field.rawStart() + len(field.Raw)
+		dst = append(dst, msg[field.Start:field.rawStart()]...)
+		if dst, err = f.applyContent(dst, field.Raw, occurrence, last, write); err == nil {
+			dst = append(dst, msg[rawEnd:field.End]...)
+		}
+	default:
+		err = errors.WithMessagef(ErrInvalidPath, "field %d of wire type %d is not a message", field.Number, field.WireType)
+	}
+	return dst, enclose(err, field.Number)
+}
+
+// applyContent appends the content of the occurrence at index occurrence to dst, with the edits
+// into all the occurrences applied, and then the ones into the occurrence if it is selected. The
+// values of the former are written only if write, the ones of the latter are always written. last
+// tells whether it is the last occurrence.
+func (f *fieldPatch) applyContent(dst, raw []byte, occurrence int, last, write bool) ([]byte, error) {
+	node := f.child
+	// rebuilt tells whether raw is rebuilt by the edits applied before
+	var rebuilt bool
+	for _, s := range f.selected {
+		if !s.seg.selects(occurrence, last) {
+			continue
+		}
+		if node != nil {
+			out, err := node.apply(nil, raw, write)
+			if err != nil {
+				return dst, rebuiltError(err, raw, rebuilt, occurrence)
+			}
+			raw, rebuilt = out, true
+		}
+		node, write = s.node, true
+	}
+	if node == nil {
+		return append(dst, raw...), nil
+	}
+	dst, err := node.apply(dst, raw, write)
+	return dst, rebuiltError(err, raw, rebuilt, occurrence)
+}
+
+// rebuiltError locates the error of the content rebuilt by the edits in the content, and wraps it
+// with the occurrence, so that it is not located again by the enclosing messages.
+func rebuiltError(err error, raw []byte, rebuilt bool, occurrence int) error {
+	if err == nil || !rebuilt {
+		return err
+	}
+	return errors.WithMessagef(locate(err, Result{Raw: raw}), "occurrence %d edited", occurrence)
+}
+
+// appendValue appends the value set to dst, with the edits into it applied.
+func (f *fieldPatch) appendValue(dst []byte, number protowire.Number) ([]byte, error) {
+	if f.child == nil && f.selected == nil {
+		return f.value.appendField(dst, number), nil
+	}
+	var err error
+	switch f.value.wireType {
+	case protowire.BytesType:
+		dst = protowire.AppendTag(dst, number, protowire.BytesType)
+		dst, err = appendLengthPrefixed(dst, func(dst []byte) ([]byte, error) {
+			return f.applyContent(dst, f.value.raw, 0, true, true)
+		})
+	case protowire.StartGroupType:
+		dst = protowire.AppendTag(dst, number, protowire.StartGroupType)
+		if dst, err = f.applyContent(dst, f.value.raw, 0, true, true); err == nil {
+			dst = protowire.AppendTag(dst, number, protowire.EndGroupType)
+		}
+	default:
+		return dst, errors.WithMessagef(ErrInvalidPath, "field %d of wire type %d is not a message", number, f.value.wireType)
+	}
+	if err != nil {
+		// the value is not sliced from the message, so its errors are located in the value and
+		// wrapped, which are not located again by the enclosing messages
+		err = errors.WithMessagef(locate(err, Result{Raw: f.value.raw}), "value of field %d", number)
+	}
+	return dst, err
+}
+
+// appendLengthPrefixed appends the content written by fn with its length as the prefix.
+func appendLengthPrefixed(dst []byte, fn func(dst []byte) ([]byte, error)) ([]byte, error) {
+	start := len(dst)
+	dst, err := fn(dst)
+	if err != nil {
+		return dst, err
+	}
+	length := len(dst) - start
+	prefix := protowire.SizeVarint(uint64(length))
+	// move the content to make room for the prefix
+	dst = append(dst, make([]byte, prefix)...)
+	copy(dst[start+prefix:], dst[start:start+length])
+	protowire.AppendVarint(dst[start:start], uint64(length))
+	return dst, nil
+}
@@ -0,0 +1,190 @@
+package gpb
+
+import (
+	"testing"
+
+	"github.com/ywx217/gpb/protoscope"
+
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPatch(t *testing.T) {
+	bs := marshalRepeatedGoTest(t)
+	original := append([]byte{}, bs...)
+
+	patch := NewPatch().
+		Set(ValueOfString("new label"), 4, 1).
+		Delete(5).
+		Append(ValueOfInt32(35), 21).
+		Set(ValueOfSint32(-3), 102)
+	require.NoError(t, patch.Err())
+	pb, err := patch.Apply(bs)
+	require.NoError(t, err)
+	require.Equal(t, original, bs, "the message is not modified")
+
+	// the same as the edits applied one by one
+	expected, err := Set(bs, ValueOfString("new label"), 4, 1)
+	require.NoError(t, err)
+	expected, err = Delete(expected, 5)
+	require.NoError(t, err)
+	expected, err = Append(expected, ValueOfInt32(35), 21)
+	require.NoError(t, err)
+	expected, err = Set(expected, ValueOfSint32(-3), 102)
+	require.NoError(t, err)
+	require.Equal(t, expected, pb)
+
+	msg := unmarshalGoTest(t, pb)
+	require.Equal(t, "new label", msg.GetRequiredField().GetLabel())
+	require.Empty(t, msg.RepeatedField)
+	require.Equal(t, int32(35), msg.F_Int32Repeated[len(msg.F_Int32Repeated)-1])
+	require.Equal(t, int32(-3), msg.GetF_Sint32Required())
+
+	// a patch can be applied again
+	again, err := patch.Apply(bs)
+	require.NoError(t, err)
+	require.Equal(t, pb, again)
+}
+
+func TestPatchOrder(t *testing.T) {
+	pb := protoscope.MustParse(`1: 1 2: {3: 3} 1: 2 4: 4`)
+
+	// a later edit overrides the earlier ones
+	out, err := NewPatch().Set(ValueOfInt32(5), 1).Delete(1).Apply(pb)
+	require.NoError(t, err)
+	require.Equal(t, protoscope.MustParse(`2: {3: 3} 4: 4`), out)
+	out, err = NewPatch().Delete(1).Set(ValueOfInt32(5), 1).Apply(pb)
+	require.NoError(t, err)
+	require.Equal(t, protoscope.MustParse(`1: 5 2: {3: 3} 4: 4`), out)
+
+	// edits into the value set before
+	out, err = NewPatch().Set(ValueOfBytes(protoscope.MustParse(`1: 1`)), 2).Set(ValueOfInt32(2), 2, 2).Apply(pb)
+	require.NoError(t, err)
+	require.Equal(t, protoscope.MustParse(`1: 1 2: {1: 1 2: 2} 1: 2 4: 4`), out)
+	out, err = NewPatch().Delete(2).Set(ValueOfInt32(2), 2, 2).Apply(pb)
+	require.NoError(t, err)
+	require.Equal(t, protoscope.MustParse(`1: 1 1: 2 4: 4 2: {2: 2}`), out)
+
+	// messages are not created for deletions
+	out, err = NewPatch().Delete(5, 1).Clear(6).Apply(pb)
+	require.NoError(t, err)
+	require.Equal(t, pb, out)
+
+	// appended after the existing ones, missing fields at the end in the order edited
+	out, err = NewPatch().Append(ValueOfInt32(7), 6).Append(ValueOfInt32(3), 1).Set(ValueOfInt32(8), 5).Apply(pb)
+	require.NoError(t, err)
+	require.Equal(t, protoscope.MustParse(`1: 1 2: {3: 3} 1: 2 4: 4 6: 7 1: 3 5: 8`), out)
+}
+
+func TestPatchOccurrences(t *testing.T) {
+	pb := protoscope.MustParse(`1: {2: {3: 1} 2: {3: 2}} 5: 5 1: {2: {3: 3}}`)
+
+	// the values are written into the last occurrences only
+	out, err := NewPatch().Set(ValueOfInt32(9), 1, 2, 3).Append(ValueOfInt32(8), 1, 4).Apply(pb)
+	require.NoError(t, err)
+	require.Equal(t, protoscope.MustParse(`1: {2: {} 2: {}} 5: 5 1: {2: {3: 9} 4: 8}`), out)
+	require.Equal(t, []int64{9}, lo.Map(GetAll(out, 1, 2, 3), func(r Result, _ int) int64 {
+		return r.Int64()
+	}))
+
+	out, err = NewPatch().Delete(1, 2, 3).Apply(pb)
+	require.NoError(t, err)
+	require.Equal(t, protoscope.MustParse(`1: {2: {} 2: {}} 5: 5 1: {2: {}}`), out)
+	out, err = NewPatch().Clear(1).Apply(pb)
+	require.NoError(t, err)
+	require.Equal(t, protoscope.MustParse(`1: {} 5: 5 1: {}`), out)
+}
+
+func TestPatchPath(t *testing.T) {
+	pb := protoscope.MustParse(`5: {1: {"a"} 2: {"x"}} 5: {1: {"b"}} 6: 6 5: {1: {"c"}}`)
+	apply := func(patch *Patch) string {
+		out, err := patch.Apply(pb)
+		require.NoError(t, err)
+		return string(out)
+	}
+
+	// only the occurrences selected are edited
+	require.Equal(t, string(protoscope.MustParse(`5: {1: {"a"} 2: {"x"}} 5: {1: {"B"}} 6: 6 5: {1: {"c"}}`)),
+		apply(NewPatch().SetPath(ValueOfString("B"), "5.#1.1")))
+	require.Equal(t, string(protoscope.MustParse(`5: {1: {"a"} 2: {"x"}} 5: {1: {"b"}} 6: 6 5: {1: {"c"} 3: 3}`)),
+		apply(NewPatch().SetPath(ValueOfInt32(3), "5.#last.3")))
+	require.Equal(t, string(protoscope.MustParse(`5: {2: {"x"}} 5: {} 6: 6 5: {1: {"c"}}`)),
+		apply(NewPatch().DeletePath("5.#:2.1")))
+	require.Equal(t, string(protoscope.MustParse(`5: {1: {"a"} 2: {"x"} 2: {"y"}} 5: {1: {"b"}} 6: 6 5: {1: {"c"}}`)),
+		apply(NewPatch().AppendPath(ValueOfString("y"), "5.#0.2")))
+	require.Equal(t, string(protoscope.MustParse(`5: {} 5: {1: {"b"}} 6: 6 5: {1: {"c"}}`)),
+		apply(NewPatch().ClearPath("5.#0")))
+
+	// the edits into all the occurrences are applied before the ones into the occurrences selected
+	require.Equal(t, string(protoscope.MustParse(`5: {2: {"x"}} 5: {1: {"B"}} 6: 6 5: {1: {"C"}}`)),
+		apply(NewPatch().Delete(5, 1).SetPath(ValueOfString("B"), "5.#1.1").SetPath(ValueOfString("C"), "5.#2:.1")))
+	require.Equal(t, string(protoscope.MustParse(`5: {1: {"a"} 2: {"x"}} 5: {1: {"b"}} 6: 6 5: {1: {"C"} 2: {"z"}}`)),
+		apply(NewPatch().SetPath(ValueOfString("z"), "5.#2.2").SetPath(ValueOfString("C"), "5.#last.1")))
+
+	// the value set is the only occurrence
+	require.Equal(t, string(protoscope.MustParse(`5: {1: {"v"} 2: {"w"}} 6: 6`)),
+		apply(NewPatch().Set(ValueOfBytes(protoscope.MustParse(`1: {"v"}`)), 5).SetPath(ValueOfString("w"), "5.#0.2")))
+
+	// nothing is edited in the occurrences missing
+	require.Equal(t, string(pb), apply(NewPatch().SetPath(ValueOfString("d"), "5.#3.1")))
+
+	for _, path := range []string{"", "5.*.1", "*", "5.#", "5.1.#0", "5.#x.1"} {
+		_, err := NewPatch().SetPath(ValueOfInt32(1), path).Apply(pb)
+		require.ErrorIs(t, err, ErrInvalidPath, path)
+	}
+	require.ErrorIs(t, NewPatch().ClearPath("5.#").Err(), ErrInvalidPath)
+}
+
+func TestPatchClear(t *testing.T) {
+	pb := protoscope.MustParse(`1: 1 2: {3: 3 4: 4} 5: !{6: 6}`)
+
+	out, err := NewPatch().Clear(2).Apply(pb)
+	require.NoError(t, err)
+	require.Equal(t, protoscope.MustParse(`1: 1 2: {} 5: !{6: 6}`), out)
+
+	out, err = NewPatch().Clear(2).Set(ValueOfInt32(7), 2, 7).Clear(5).Apply(pb)
+	require.NoError(t, err)
+	require.Equal(t, protoscope.MustParse(`1: 1 2: {7: 7} 5: !{}`), out)
+
+	out, err = NewPatch().Clear().Set(ValueOfInt32(2), 1).Apply(pb)
+	require.NoError(t, err)
+	require.Equal(t, protoscope.MustParse(`1: 2`), out)
+}
+
+func TestPatchError(t *testing.T) {
+	pb := protoscope.MustParse(`1: 1`)
+
+	patch := NewPatch().Delete().Set(ValueOfInt32(1), 1)
+	require.ErrorIs(t, patch.Err(), ErrInvalidPath)
+	out, err := patch.ApplyInto([]byte("dst"), pb)
+	require.ErrorIs(t, err, ErrInvalidPath)
+	require.Equal(t, []byte("dst"), out)
+
+	_, err = NewPatch().Set(ValueOfResult(Result{WireType: InvalidWireType}), 1).Apply(pb)
+	require.ErrorIs(t, err, ErrUnknownWireType)
+
+	_, err = NewPatch().Set(ValueOfInt32(1), 1, 2).Apply(pb)
+	require.ErrorIs(t, err, ErrInvalidPath)
+
+	// malformed nested message
+	out, err = NewPatch().Set(ValueOfInt32(1), 4, 1).ApplyInto([]byte("dst"), protoscope.MustParse(`1: 1 4: {`+"`0a05`"+`}`))
+	var pe *ParseError
+	require.ErrorAs(t, err, &pe)
+	require.Equal(t, 4, pe.Offset)
+	require.Equal(t, []byte("dst"), out)
+
+	// malformed message set, the error is located in the value rather than in pb
+	value := ValueOfBytes(protoscope.MustParse(`1: 1 ` + "`0a05`"))
+	_, err = NewPatch().Set(value, 4).Set(ValueOfInt32(2), 4, 3).Apply(pb)
+	require.ErrorAs(t, err, &pe)
+	require.Equal(t, 2, pe.Offset)
+	require.Empty(t, pe.Path)
+	require.Equal(t, "value of field 4: offset=2 field=1 wire_type=2: invalid length", err.Error())
+
+	// malformed message rebuilt by the edits into all the occurrences, the error is located in the
+	// content rebuilt
+	pb = protoscope.MustParse(`1: 1 4: {1: 1 3: {` + "`0a05`" + `}}`)
+	_, err = NewPatch().Delete(4, 1).SetPath(ValueOfInt32(2), "4.#0.3.1").Apply(pb)
+	require.ErrorAs(t, err, &pe)
+	require.Equal(t, "occurrence 0 edited: offset=2 path=3 field=1 wire_type=2: invalid length", err.Error())
+}
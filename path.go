@@ -0,0 +1,263 @@
+package gpb
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// Path syntax
+//
+// A path is a series of segments separated by dots, which is walked from the root message
+// to the desired field:
+//   `4`   a field number, e.g. `4.1` is field 1 of the embedded message in field 4
+//   `*`   a wildcard, which matches any field number
+//   `#2`  the occurrence at index 2 (zero based) of the field selected by the previous segment,
+//         e.g. `5.#1.2` is field 2 of the second message in repeated field 5
+//   `#last`  the last occurrence of the field selected by the previous segment
+//   `#1:3`   the occurrences in range [1, 3) of the field selected by the previous segment, either
+//            bound can be omitted, e.g. `#2:` selects from the third occurrence to the end
+//   `#`   as the last segment, the number of values matched by the leading path, e.g. `5.#`
+//
+// Only length-delimited fields and groups are descended into. Length-delimited fields matched
+// by a wildcard are not necessarily messages, so parse errors below a wildcard segment are
+// ignored and the field is simply skipped.
+//
+// Selectors can be applied at every depth of the path, and each level of the message is read
+// through only once without heap allocation.
+
+const (
+	pathSeparator = '.'
+	pathWildcard  = "*"
+	pathSelector  = '#'
+	pathLast      = "last"
+	pathRange     = ':'
+
+	// anyNumber the field number of a wildcard segment, it is not a valid field number
+	anyNumber protowire.Number = 0
+	// inlinePathSegments paths up to this length are parsed without heap allocation
+	inlinePathSegments = 8
+)
+
+type selector uint8
+
+const (
+	selectAll selector = iota
+	selectIndex
+	selectLast
+	selectRange
+)
+
+type pathSegment struct {
+	number   protowire.Number // anyNumber for the wildcard segment
+	selector selector
+	index    int // the selected index, or the start of range
+	end      int // the end of range (exclusive), negative for unbounded
+}
+
+// match reports whether the field number is selected by the segment.
+func (s *pathSegment) match(fieldNumber protowire.Number) bool {
+	return s.number == anyNumber || s.number == fieldNumber
+}
+
+// selects reports whether the occurrence at index is selected by the segment, last tells whether
+// it is the last occurrence.
+func (s *pathSegment) selects(index int, last bool) bool {
+	switch s.selector {
+	case selectIndex:
+		return index == s.index
+	case selectLast:
+		return last
+	case selectRange:
+		return index >= s.index && (s.end < 0 || index < s.end)
+	}
+	return true
+}
+
+type fieldPath struct {
+	segments []pathSegment
+	count    bool // the path ends with `#`
+}
+
+// Get searches pb for the given path, and returns the first value matched. When nothing is
+// matched or the path is invalid, a Result with InvalidWireType is returned. There is no
+// heap-memory allocation for paths with no more than 8 segments.
+func Get(pb []byte, path string) Result {
+	state := Result{Raw: pb}
+	return state.Get(path)
+}
+
+// GetSingular searches pb for the given path, and returns the last value matched, which follows
+// the protobuf specification for non-repeated fields. See Result.GetLast for details.
+func GetSingular(pb []byte, path string) Result {
+	state := Result{Raw: pb}
+	return state.GetSingular(path)
+}
+
+// GetMany searches pb for each of the given paths, and returns the first value of each path.
+func GetMany(pb []byte, paths ...string) []Result {
+	state := Result{Raw: pb}
+	return state.GetMany(paths...)
+}
+
+// ForEachPath iterates through all the values matched by the given path until the iterator
+// returns false. The error of an invalid path or a malformed message is returned.
+func ForEachPath(pb []byte, path string, iterator func(Result) bool) error {
+	state := Result{Raw: pb}
+	return state.ForEachPath(path, iterator)
+}
+
+// Get searches r.Raw for the given path, and returns the first value matched.
+// See the package level Get for details.
+func (r Result) Get(path string) (result Result) {
+	result.WireType = InvalidWireType
+	_ = r.ForEachPath(path, func(r Result) bool {
+		result = r
+		return false
+	})
+	return
+}
+
+// GetSingular searches r.Raw for the given path, and returns the last value matched. All the
+// occurrences of the intermediate messages are walked through, so they are logically merged.
+// Selectors still apply to the occurrences on the wire.
+func (r Result) GetSingular(path string) (result Result) {
+	result.WireType = InvalidWireType
+	_ = r.ForEachPath(path, func(r Result) bool {
+		result = r
+		return true
+	})
+	return
+}
+
+// GetMany searches r.Raw for each of the given paths, and returns the first value of each path.
+// The message is read through only once, see MultiQuery for details. Invalid paths get a Result
+// with InvalidWireType.
+func (r Result) GetMany(paths ...string) []Result {
+	results := make([]Result, len(paths))
+	m, _ := compileMany(paths, true)
+	_ = m.GetMany(r.Raw, results)
+	return results
+}
+
+// ForEachPath iterates through all the values in r.Raw matched by the given path until the
+// iterator returns false.
+func (r Result) ForEachPath(path string, iterator func(Result) bool) error {
+	var buf [inlinePathSegments]pathSegment
+	p, err := parsePath(path, buf[:0], resolveFieldNumber)
+	if err != nil {
+		return err
+	}
+	return r.walkPath(p, nil, iterator)
+}
+
+// walkPath walks the parsed path with the walker of GetIter, feeding the matched values to the
+// resultSink. Paths ending with `#` feed a single varint result holding the count. The limiter
+// may be nil for no limits.
+func (r Result) walkPath(p fieldPath, l *limiter, resultSink func(Result) bool) error {
+	if !p.count {
+		w := iterWalker{segments: p.segments, sink: resultSink, limits: l}
+		return locate(w.walk(r, 0), r)
+	}
+	var count uint64
+	w := iterWalker{segments: p.segments, limits: l, sink: func(Result) bool {
+		count++
+		return true
+	}}
+	if err := w.walk(r, 0); err != nil {
+		return locate(err, r)
+	}
+	resultSink(Result{WireType: protowire.VarintType, Varint: count})
+	return nil
+}
+
+// fieldResolver translates a field segment of the path into a field number.
+type fieldResolver func(token string) (protowire.Number, error)
+
+// parsePath parses the path into segments appended to the given slice, so callers may provide
+// a stack allocated buffer. Field segments are translated by the resolver.
+func parsePath(path string, segments []pathSegment, resolve fieldResolver) (fieldPath, error) {
+	p := fieldPath{segments: segments}
+	if path == "" {
+		return p, errors.WithMessage(ErrInvalidPath, "empty path")
+	}
+	for rest := path; ; {
+		token := rest
+		sep := strings.IndexByte(rest, pathSeparator)
+		if sep >= 0 {
+			token, rest = rest[:sep], rest[sep+1:]
+		}
+		if p.count {
+			return p, errors.WithMessagef(ErrInvalidPath, "`#` must be the last segment, path=%q", path)
+		}
+		if len(token) > 0 && token[0] == pathSelector {
+			if len(p.segments) == 0 {
+				return p, errors.WithMessagef(ErrInvalidPath, "selector without field, path=%q", path)
+			}
+			if len(token) == 1 {
+				p.count = true
+			} else if last := &p.segments[len(p.segments)-1]; last.selector != selectAll {
+				return p, errors.WithMessagef(ErrInvalidPath, "duplicated selector %q, path=%q", token, path)
+			} else if err := parseSelector(token[1:], last); err != nil {
+				return p, errors.WithMessagef(err, "path=%q", path)
+			}
+		} else {
+			number, err := resolve(token)
+			if err != nil {
+				return p, errors.WithMessagef(err, "path=%q", path)
+			}
+			p.segments = append(p.segments, pathSegment{number: number})
+		}
+		if sep < 0 {
+			return p, nil
+		}
+	}
+}
+
+// resolveFieldNumber resolves the numeric field segments and the wildcard.
+func resolveFieldNumber(token string) (protowire.Number, error) {
+	if token == pathWildcard {
+		return anyNumber, nil
+	}
+	number, err := strconv.ParseInt(token, 10, 32)
+	if err != nil || !protowire.Number(number).IsValid() {
+		return 0, errors.WithMessagef(ErrInvalidPath, "bad field number %q", token)
+	}
+	return protowire.Number(number), nil
+}
+
+// parseSelector parses the selector with the leading `#` removed into the segment.
+func parseSelector(token string, seg *pathSegment) error {
+	if token == pathLast {
+		seg.selector = selectLast
+		return nil
+	}
+	sep := strings.IndexByte(token, pathRange)
+	if sep < 0 {
+		index, err := strconv.Atoi(token)
+		if err != nil || index < 0 {
+			return errors.WithMessagef(ErrInvalidPath, "bad index %q", token)
+		}
+		seg.selector = selectIndex
+		seg.index = index
+		return nil
+	}
+	start, end := 0, -1
+	var err error
+	if bound := token[:sep]; bound != "" {
+		if start, err = strconv.Atoi(bound); err != nil || start < 0 {
+			return errors.WithMessagef(ErrInvalidPath, "bad range %q", token)
+		}
+	}
+	if bound := token[sep+1:]; bound != "" {
+		if end, err = strconv.Atoi(bound); err != nil || end < start {
+			return errors.WithMessagef(ErrInvalidPath, "bad range %q", token)
+		}
+	}
+	seg.selector = selectRange
+	seg.index = start
+	seg.end = end
+	return nil
+}
@@ -0,0 +1,194 @@
+package gpb
+
+import (
+	"testing"
+
+	"github.com/ywx217/gpb/internal/testprotos"
+
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+)
+
+func marshalRepeatedGoTest(t testing.TB) []byte {
+	msg := initGoTest(false)
+	msg.RepeatedField = []*testprotos.GoTestField{
+		{Label: proto.String("l0"), Type: proto.String("t0")},
+		{Label: proto.String("l1"), Type: proto.String("t1")},
+		{Label: proto.String("l2"), Type: proto.String("t2")},
+	}
+	msg.F_Int32Repeated = []int32{32, 33, 34}
+	bs, err := proto.Marshal(msg)
+	require.NoError(t, err)
+	return bs
+}
+
+func TestGetPath(t *testing.T) {
+	bs := marshalRepeatedGoTest(t)
+
+	require.Equal(t, int32(testprotos.GoTest_TIME), Get(bs, "1").Int32())
+	require.Equal(t, "label", Get(bs, "4.1").String())
+	require.Equal(t, "type", Get(bs, "4.2").String())
+	require.Equal(t, "required", Get(bs, "70.71").String())
+	require.Equal(t, "l0", Get(bs, "5.1").String())
+	require.Equal(t, GetOne(bs, 4, 1), Get(bs, "4.1"))
+
+	require.False(t, Get(bs, "6.1").Exist())
+	require.False(t, Get(bs, "11.1").Exist(), "scalars have no fields inside")
+	require.False(t, Get(bs, "4..1").Exist(), "invalid path")
+}
+
+func TestGetPathIndex(t *testing.T) {
+	bs := marshalRepeatedGoTest(t)
+
+	require.Equal(t, "l0", Get(bs, "5.#0.1").String())
+	require.Equal(t, "t1", Get(bs, "5.#1.2").String())
+	require.Equal(t, "l2", Get(bs, "5.#2.1").String())
+	require.False(t, Get(bs, "5.#3.1").Exist())
+	require.Equal(t, int32(34), Get(bs, "21.#2").Int32())
+}
+
+func TestGetPathLast(t *testing.T) {
+	bs := marshalRepeatedGoTest(t)
+
+	require.Equal(t, "l2", Get(bs, "5.#last.1").String())
+	require.Equal(t, int32(34), Get(bs, "21.#last").Int32())
+	require.Equal(t, "string", Get(bs, "19.#last").String())
+	require.False(t, Get(bs, "6.#last").Exist())
+	require.Equal(t, uint64(1), Get(bs, "5.#last.#").Uint64())
+}
+
+func TestGetPathRange(t *testing.T) {
+	bs := marshalRepeatedGoTest(t)
+	collect := func(path string) []string {
+		var values []string
+		require.NoError(t, ForEachPath(bs, path, func(r Result) bool {
+			values = append(values, r.String())
+			return true
+		}))
+		return values
+	}
+
+	require.Equal(t, []string{"l1", "l2"}, collect("5.#1:3.1"))
+	require.Equal(t, []string{"l1", "l2"}, collect("5.#1:.1"))
+	require.Equal(t, []string{"t0", "t1"}, collect("5.#:2.2"))
+	require.Equal(t, []string{"t0", "t1", "t2"}, collect("5.#:.2"))
+	require.Empty(t, collect("5.#1:1.2"))
+	require.Empty(t, collect("5.#3:.2"))
+	require.Equal(t, uint64(2), Get(bs, "21.#1:.#").Uint64())
+}
+
+func TestGetPathSelectorDepth(t *testing.T) {
+	msg := &testprotos.GoTest_RepeatedGroup{RequiredField: proto.String("x")}
+	inner, err := proto.Marshal(msg)
+	require.NoError(t, err)
+	var bs []byte
+	for i := 0; i < 3; i++ {
+		// field 1 holds messages with two occurrences of field 81 and a varint field 3 inside
+		nested := append(append([]byte(nil), inner...), inner...)
+		nested = append(nested, 0x18, byte(i))
+		bs = protowire.AppendTag(bs, 1, protowire.BytesType)
+		bs = protowire.AppendBytes(bs, nested)
+	}
+
+	require.Equal(t, int32(2), Get(bs, "1.#last.3").Int32())
+	require.Equal(t, int32(1), Get(bs, "1.#1.3").Int32())
+	require.Equal(t, uint64(2), Get(bs, "1.#1:.3.#").Uint64())
+	require.Equal(t, uint64(6), Get(bs, "1.81.#").Uint64())
+	require.Equal(t, uint64(2), Get(bs, "1.#last.81.#").Uint64())
+	require.Equal(t, uint64(3), Get(bs, "1.81.#last.#").Uint64(), "last occurrence inside each message")
+}
+
+func TestGetSingular(t *testing.T) {
+	a := marshalRepeatedGoTest(t)
+	b, err := proto.MarshalOptions{AllowPartial: true}.Marshal(&testprotos.GoTest{
+		RequiredField: &testprotos.GoTestField{Label: proto.String("merged")},
+		Requiredgroup: &testprotos.GoTest_RequiredGroup{RequiredField: proto.String("merged")},
+	})
+	require.NoError(t, err)
+	bs := append(a, b...)
+
+	var merged testprotos.GoTest
+	require.NoError(t, proto.Unmarshal(bs, &merged))
+	require.Equal(t, merged.GetRequiredField().GetLabel(), GetSingular(bs, "4.1").String())
+	require.Equal(t, merged.GetRequiredField().GetType(), GetSingular(bs, "4.2").String())
+	require.Equal(t, merged.GetRequiredgroup().GetRequiredField(), GetSingular(bs, "70.71").String())
+	require.Equal(t, "label", Get(bs, "4.1").String(), "Get returns the first value")
+	require.Equal(t, "l0", GetSingular(bs, "5.#0.1").String(), "selectors apply to the occurrences on the wire")
+	require.False(t, GetSingular(bs, "4..1").Exist())
+}
+
+func TestGetPathCount(t *testing.T) {
+	bs := marshalRepeatedGoTest(t)
+
+	require.Equal(t, uint64(3), Get(bs, "5.#").Uint64())
+	require.Equal(t, uint64(3), Get(bs, "5.1.#").Uint64())
+	require.Equal(t, uint64(1), Get(bs, "5.#1.#").Uint64())
+	require.Equal(t, uint64(0), Get(bs, "6.#").Uint64())
+	require.Equal(t, uint64(3), Get(bs, "21.#").Uint64())
+}
+
+func TestGetPathWildcard(t *testing.T) {
+	bs := marshalRepeatedGoTest(t)
+
+	var labels []string
+	require.NoError(t, ForEachPath(bs, "*.1", func(r Result) bool {
+		labels = append(labels, r.String())
+		return true
+	}))
+	require.Equal(t, []string{"label", "l0", "l1", "l2"}, labels)
+	require.Equal(t, "required", Get(bs, "70.*").String())
+}
+
+func TestForEachPath(t *testing.T) {
+	bs := marshalRepeatedGoTest(t)
+
+	var values []int32
+	require.NoError(t, ForEachPath(bs, "21", func(r Result) bool {
+		values = append(values, r.Int32())
+		return len(values) < 2
+	}))
+	require.Equal(t, []int32{32, 33}, values)
+
+	require.ErrorIs(t, ForEachPath(bs, "5.#a", func(Result) bool { return true }), ErrInvalidPath)
+	require.ErrorIs(t, ForEachPath(bs[:len(bs)-1], "1000", func(Result) bool { return true }), ErrInvalidLength)
+	require.ErrorIs(t, ForEachPath([]byte{0x00, 0x01}, "1", func(Result) bool { return true }), ErrInvalidLength, "field number 0")
+}
+
+func TestGetMany(t *testing.T) {
+	bs := marshalRepeatedGoTest(t)
+
+	results := GetMany(bs, "4.1", "5.#2.2", "19", "6")
+	require.Equal(t, []string{"label", "t2", "string", ""}, lo.Map(results, func(r Result, _ int) string {
+		return r.String()
+	}))
+	require.False(t, results[3].Exist())
+}
+
+func TestParsePath(t *testing.T) {
+	for _, path := range []string{"", ".", "1.", "0", "-1", "536870912", "#", "#1", "1.#1.#2", "1.#.2", "1.#-1", "a", "1.#3:1", "1.#a:", "1.#:b", "1.#lastt"} {
+		_, err := parsePath(path, nil, resolveFieldNumber)
+		require.ErrorIs(t, err, ErrInvalidPath, path)
+	}
+	p, err := parsePath("4.#2.*.1.#last.3.#1:.2.#:4.#", nil, resolveFieldNumber)
+	require.NoError(t, err)
+	require.True(t, p.count)
+	require.Equal(t, []pathSegment{
+		{number: 4, selector: selectIndex, index: 2},
+		{number: anyNumber},
+		{number: 1, selector: selectLast},
+		{number: 3, selector: selectRange, index: 1, end: -1},
+		{number: 2, selector: selectRange, index: 0, end: 4},
+	}, p.segments)
+}
+
+func TestGetPathNoAlloc(t *testing.T) {
+	bs := marshalRepeatedGoTest(t)
+	for _, path := range []string{"5.#2.1", "5.#last.1", "5.#1:.2", "21.#"} {
+		allocs := testing.AllocsPerRun(100, func() {
+			_ = Get(bs, path)
+		})
+		require.Zero(t, allocs, path)
+	}
+}
@@ -0,0 +1,185 @@
+// Package protoscope converts between the protobuf wire format and the Protoscope language, so
+// that test fixtures, including the malformed ones, can be written as readable text.
+//
+// The subset of the language supported:
+//
+//	1: 42           tag with the wire type inferred from the value, VARINT here
+//	1:VARINT 42     tag with explicit wire type, one of VARINT, I64, LEN, SGROUP, EGROUP, I32 or 0-7
+//	-5z             zigzag encoded varint
+//	5i32 1.5i32     fixed32 integer and float
+//	5i64 1.5        fixed64 integer and double
+//	true false      varint 1 and 0
+//	"text" `0aff`   raw bytes, as quoted string or hex
+//	{ ... }         length prefixed block
+//	1: !{ ... }     group, the end group tag is written after the block
+//	# comment       to the end of the line
+//
+// Language specification: https://github.com/protocolbuffers/protoscope/blob/main/language.txt
+package protoscope
+
+import (
+	"encoding/hex"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// Format renders the buffer in the Protoscope language, Parse(Format(pb)) always equals to pb.
+// A length-delimited field is rendered as a message if it holds well-formed fields only,
+// otherwise as a string if it is printable, or as hex. The bytes that can not be rendered as
+// fields, e.g. malformed or non-canonical ones, are rendered as hex.
+func Format(pb []byte) string {
+	var f formatter
+	f.fields(pb, 0)
+	return f.sb.String()
+}
+
+type formatter struct {
+	sb strings.Builder
+}
+
+func (f *formatter) fields(pb []byte, indent int) {
+	for len(pb) > 0 {
+		n := f.field(pb, indent)
+		if n <= 0 {
+			f.line(indent, "`"+hex.EncodeToString(pb)+"`")
+			return
+		}
+		pb = pb[n:]
+	}
+}
+
+// field renders the first field of pb, returns the length consumed or a negative value if it is
+// not rendered.
+func (f *formatter) field(pb []byte, indent int) int {
+	num, typ, n := protowire.ConsumeTag(pb)
+	if n < 0 || n != protowire.SizeTag(num) {
+		return -1
+	}
+	tag := strconv.FormatUint(uint64(num), 10) + ": "
+	switch typ {
+	case protowire.VarintType:
+		v, m := protowire.ConsumeVarint(pb[n:])
+		if m < 0 || m != protowire.SizeVarint(v) {
+			return -1
+		}
+		f.line(indent, tag+strconv.FormatInt(int64(v), 10))
+		return n + m
+	case protowire.Fixed32Type:
+		v, m := protowire.ConsumeFixed32(pb[n:])
+		if m < 0 {
+			return -1
+		}
+		f.line(indent, tag+strconv.FormatInt(int64(int32(v)), 10)+"i32")
+		return n + m
+	case protowire.Fixed64Type:
+		v, m := protowire.ConsumeFixed64(pb[n:])
+		if m < 0 {
+			return -1
+		}
+		f.line(indent, tag+strconv.FormatInt(int64(v), 10)+"i64")
+		return n + m
+	case protowire.BytesType:
+		v, m := protowire.ConsumeBytes(pb[n:])
+		if m < 0 || m != protowire.SizeBytes(len(v)) {
+			return -1
+		}
+		f.block(indent, tag+"{", v)
+		return n + m
+	case protowire.StartGroupType:
+		v, m := protowire.ConsumeGroup(num, pb[n:])
+		if m < 0 || m != len(v)+protowire.SizeTag(num) {
+			return -1
+		}
+		f.line(indent, tag+"!{")
+		f.fields(v, indent+1)
+		f.line(indent, "}")
+		return n + m
+	case protowire.EndGroupType:
+		f.line(indent, strconv.FormatUint(uint64(num), 10)+":EGROUP")
+		return n
+	default:
+		return -1
+	}
+}
+
+// block renders the content of a length-delimited field after the opening.
+func (f *formatter) block(indent int, opening string, v []byte) {
+	switch {
+	case len(v) == 0:
+		f.line(indent, opening+"}")
+	case isMessage(v):
+		f.line(indent, opening)
+		f.fields(v, indent+1)
+		f.line(indent, "}")
+	case isPrintable(v):
+		f.line(indent, opening+quote(v)+"}")
+	default:
+		f.line(indent, opening+"`"+hex.EncodeToString(v)+"`}")
+	}
+}
+
+func (f *formatter) line(indent int, s string) {
+	f.sb.WriteString(strings.Repeat("  ", indent))
+	f.sb.WriteString(s)
+	f.sb.WriteByte('\n')
+}
+
+// isMessage reports whether pb holds well-formed fields only, stray end group tags are not
+// allowed.
+func isMessage(pb []byte) bool {
+	for len(pb) > 0 {
+		num, typ, n := protowire.ConsumeField(pb)
+		if n < 0 || typ == protowire.EndGroupType {
+			return false
+		}
+		if typ == protowire.StartGroupType {
+			_, _, tagLen := protowire.ConsumeTag(pb)
+			if v, _ := protowire.ConsumeGroup(num, pb[tagLen:]); len(v) > 0 && !isMessage(v) {
+				return false
+			}
+		}
+		pb = pb[n:]
+	}
+	return true
+}
+
+// isPrintable reports whether pb is UTF-8 text without control characters but the white spaces.
+func isPrintable(pb []byte) bool {
+	for len(pb) > 0 {
+		r, n := utf8.DecodeRune(pb)
+		if r == utf8.RuneError && n <= 1 {
+			return false
+		}
+		if !unicode.IsPrint(r) && r != '\n' && r != '\t' && r != '\r' {
+			return false
+		}
+		pb = pb[n:]
+	}
+	return true
+}
+
+func quote(pb []byte) string {
+	var sb strings.Builder
+	sb.WriteByte('"')
+	for _, c := range pb {
+		switch c {
+		case '"', '\\':
+			sb.WriteByte('\\')
+			sb.WriteByte(c)
+		case '\n':
+			sb.WriteString(`\n`)
+		case '\t':
+			sb.WriteString(`\t`)
+		case '\r':
+			sb.WriteString(`\r`)
+		default:
+			sb.WriteByte(c)
+		}
+	}
+	sb.WriteByte('"')
+	return sb.String()
+}
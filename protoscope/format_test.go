@@ -0,0 +1,79 @@
+package protoscope
+
+import (
+	"testing"
+
+	"github.com/ywx217/gpb/internal/testprotos"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+)
+
+var partialMarshal = proto.MarshalOptions{AllowPartial: true}
+
+func TestFormat(t *testing.T) {
+	msg := &testprotos.GoTest{
+		Kind:               testprotos.GoTest_TIME.Enum(),
+		RequiredField:      &testprotos.GoTestField{Label: proto.String("label"), Type: proto.String("a \"b\"\n")},
+		F_Fixed32Required:  proto.Uint32(32),
+		F_Sfixed64Required: proto.Int64(-64),
+		F_BytesRequired:    []byte{0xff, 0x00},
+		F_StringRequired:   proto.String(""),
+		Requiredgroup:      &testprotos.GoTest_RequiredGroup{RequiredField: proto.String("required")},
+	}
+	bs, err := partialMarshal.Marshal(msg)
+	require.NoError(t, err)
+	text := Format(bs)
+	require.Equal(t, ""+
+		"1: 7\n"+
+		"4: {\n"+
+		"  1: {\"label\"}\n"+
+		"  2: {\"a \\\"b\\\"\\n\"}\n"+
+		"}\n"+
+		"13: 32i32\n"+
+		"19: {}\n"+
+		"70: !{\n"+
+		"  71: {\"required\"}\n"+
+		"}\n"+
+		"101: {`ff00`}\n"+
+		"105: -64i64\n",
+		text)
+	require.Equal(t, bs, MustParse(text))
+}
+
+func TestFormatMalformed(t *testing.T) {
+	for text, expected := range map[string]string{
+		// truncated length
+		"1: 1 2: {`0a05`}": "1: 1\n2: {`0a05`}\n",
+		"1: 1 `0a05`":      "1: 1\n`0a05`\n",
+		// non-canonical varint
+		"1: 1 `088100`": "1: 1\n`088100`\n",
+		// stray end group and unknown wire type
+		"1:EGROUP 1:6 1": "1:EGROUP\n`0e01`\n",
+		// group not closed
+		"1: { 2:SGROUP 3: 1 }": "1: {`131801`}\n",
+	} {
+		pb := MustParse(text)
+		require.Equal(t, expected, Format(pb), text)
+		require.Equal(t, pb, MustParse(Format(pb)), text)
+	}
+}
+
+func FuzzFormat(f *testing.F) {
+	full := &testprotos.GoTest{
+		Kind:                  testprotos.GoTest_TIME.Enum(),
+		RequiredField:         &testprotos.GoTestField{Label: proto.String("label")},
+		Requiredgroup:         &testprotos.GoTest_RequiredGroup{RequiredField: proto.String("required")},
+		F_Int32RepeatedPacked: []int32{1, -1},
+	}
+	bs, err := partialMarshal.Marshal(full)
+	require.NoError(f, err)
+	f.Add(bs)
+	f.Add([]byte{0x0b, 0x0b, 0x0c})
+	f.Add([]byte{0x0a, 0x03, 0xe4, 0xb8, 0xad})
+	f.Fuzz(func(t *testing.T, pb []byte) {
+		actual, err := Parse(Format(pb))
+		require.NoError(t, err)
+		require.Equal(t, pb, append(pb[:0:0], actual...))
+	})
+}
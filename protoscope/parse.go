@@ -0,0 +1,331 @@
+package protoscope
+
+import (
+	"encoding/hex"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+var ErrSyntax = errors.New("protoscope syntax error")
+
+var wireTypes = map[string]protowire.Type{
+	"VARINT": protowire.VarintType,
+	"I64":    protowire.Fixed64Type,
+	"LEN":    protowire.BytesType,
+	"SGROUP": protowire.StartGroupType,
+	"EGROUP": protowire.EndGroupType,
+	"I32":    protowire.Fixed32Type,
+}
+
+// Parse encodes the Protoscope text into bytes.
+func Parse(text string) ([]byte, error) {
+	p := parser{src: text, line: 1}
+	pb, err := p.block(nil, false)
+	if err != nil {
+		return nil, errors.WithMessagef(err, "line %d", p.line)
+	}
+	return pb, nil
+}
+
+// MustParse is like Parse but panics if the text can not be parsed, it simplifies the
+// initialization of test fixtures.
+func MustParse(text string) []byte {
+	pb, err := Parse(text)
+	if err != nil {
+		panic(errors.WithMessage(err, "protoscope: parse"))
+	}
+	return pb
+}
+
+type parser struct {
+	src  string
+	pos  int
+	line int
+}
+
+// block appends the tokens to pb until the closing brace, or the end of text for the top level.
+func (p *parser) block(pb []byte, nested bool) ([]byte, error) {
+	for {
+		token, err := p.next()
+		if err != nil {
+			return nil, err
+		}
+		switch token {
+		case "":
+			if nested {
+				return nil, errors.WithMessage(ErrSyntax, "unclosed block")
+			}
+			return pb, nil
+		case "}":
+			if !nested {
+				return nil, errors.WithMessage(ErrSyntax, "unexpected }")
+			}
+			return pb, nil
+		case "{":
+			if pb, err = p.lengthPrefixed(pb); err != nil {
+				return nil, err
+			}
+		case "!{":
+			return nil, errors.WithMessage(ErrSyntax, "group without tag")
+		default:
+			if pb, err = p.value(pb, token); err != nil {
+				return nil, err
+			}
+		}
+	}
+}
+
+func (p *parser) lengthPrefixed(pb []byte) ([]byte, error) {
+	content, err := p.block(nil, true)
+	if err != nil {
+		return nil, err
+	}
+	return protowire.AppendBytes(pb, content), nil
+}
+
+// value appends the token other than braces to pb.
+func (p *parser) value(pb []byte, token string) ([]byte, error) {
+	switch token[0] {
+	case '"':
+		s, err := unquote(token)
+		if err != nil {
+			return nil, err
+		}
+		return append(pb, s...), nil
+	case '`':
+		bs, err := hex.DecodeString(token[1 : len(token)-1])
+		if err != nil {
+			return nil, errors.WithMessagef(ErrSyntax, "bad hex %s", token)
+		}
+		return append(pb, bs...), nil
+	}
+	if i := strings.IndexByte(token, ':'); i >= 0 {
+		return p.tag(pb, token[:i], token[i+1:])
+	}
+	return appendNumber(pb, token)
+}
+
+// tag appends the tag, the wire type is inferred from the next token if it is omitted.
+func (p *parser) tag(pb []byte, number, wireType string) ([]byte, error) {
+	num, err := strconv.ParseUint(number, 10, 31)
+	if err != nil {
+		return nil, errors.WithMessagef(ErrSyntax, "bad field number %q", number)
+	}
+	if wireType != "" {
+		typ, ok := wireTypes[wireType]
+		if !ok {
+			v, err := strconv.ParseUint(wireType, 10, 3)
+			if err != nil {
+				return nil, errors.WithMessagef(ErrSyntax, "bad wire type %q", wireType)
+			}
+			typ = protowire.Type(v)
+		}
+		return protowire.AppendTag(pb, protowire.Number(num), typ), nil
+	}
+
+	token, err := p.next()
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case token == "{":
+		pb = protowire.AppendTag(pb, protowire.Number(num), protowire.BytesType)
+		return p.lengthPrefixed(pb)
+	case token == "!{":
+		pb = protowire.AppendTag(pb, protowire.Number(num), protowire.StartGroupType)
+		if pb, err = p.block(pb, true); err != nil {
+			return nil, err
+		}
+		return protowire.AppendTag(pb, protowire.Number(num), protowire.EndGroupType), nil
+	case token == "" || token == "}" || token[0] == '"' || token[0] == '`' || strings.IndexByte(token, ':') >= 0:
+		return nil, errors.WithMessagef(ErrSyntax, "can not infer the wire type of field %d", num)
+	}
+	typ := protowire.VarintType
+	if strings.HasSuffix(token, "i32") {
+		typ = protowire.Fixed32Type
+	} else if strings.HasSuffix(token, "i64") || isFloat(token) {
+		typ = protowire.Fixed64Type
+	}
+	pb = protowire.AppendTag(pb, protowire.Number(num), typ)
+	return appendNumber(pb, token)
+}
+
+// appendNumber appends the number literal, which is a varint unless it is suffixed or a float.
+func appendNumber(pb []byte, token string) ([]byte, error) {
+	switch token {
+	case "true":
+		return protowire.AppendVarint(pb, 1), nil
+	case "false":
+		return protowire.AppendVarint(pb, 0), nil
+	}
+	literal, suffix := token, ""
+	for _, s := range []string{"i32", "i64", "z"} {
+		if strings.HasSuffix(token, s) {
+			literal, suffix = strings.TrimSuffix(token, s), s
+			break
+		}
+	}
+	if isFloat(literal) {
+		bits := 64
+		if suffix == "i32" {
+			bits = 32
+		} else if suffix == "z" {
+			return nil, errors.WithMessagef(ErrSyntax, "bad number %q", token)
+		}
+		f, err := strconv.ParseFloat(literal, bits)
+		if err != nil {
+			return nil, errors.WithMessagef(ErrSyntax, "bad number %q", token)
+		}
+		if bits == 32 {
+			return protowire.AppendFixed32(pb, math.Float32bits(float32(f))), nil
+		}
+		return protowire.AppendFixed64(pb, math.Float64bits(f)), nil
+	}
+
+	v, err := parseInt(literal)
+	if err != nil {
+		return nil, errors.WithMessagef(ErrSyntax, "bad number %q", token)
+	}
+	switch suffix {
+	case "i32":
+		if (literal[0] == '-' && int64(v) < math.MinInt32) || (literal[0] != '-' && v > math.MaxUint32) {
+			return nil, errors.WithMessagef(ErrSyntax, "%q overflows i32", token)
+		}
+		return protowire.AppendFixed32(pb, uint32(v)), nil
+	case "i64":
+		return protowire.AppendFixed64(pb, v), nil
+	case "z":
+		if literal[0] != '-' && v > math.MaxInt64 {
+			return nil, errors.WithMessagef(ErrSyntax, "%q overflows zigzag", token)
+		}
+		return protowire.AppendVarint(pb, protowire.EncodeZigZag(int64(v))), nil
+	default:
+		return protowire.AppendVarint(pb, v), nil
+	}
+}
+
+// parseInt parses the integer in the range of int64 or uint64, the negative ones are in two's
+// complement.
+func parseInt(literal string) (uint64, error) {
+	if strings.HasPrefix(literal, "-") {
+		v, err := strconv.ParseInt(literal, 0, 64)
+		return uint64(v), err
+	}
+	return strconv.ParseUint(literal, 0, 64)
+}
+
+func isFloat(literal string) bool {
+	literal = strings.TrimPrefix(literal, "-")
+	if literal == "inf" || literal == "nan" {
+		return true
+	}
+	if literal == "" || strings.HasPrefix(literal, "0x") || (literal[0] != '.' && (literal[0] < '0' || literal[0] > '9')) {
+		return false
+	}
+	return strings.ContainsAny(literal, ".eE")
+}
+
+// next returns the next token, or an empty string at the end of text.
+func (p *parser) next() (string, error) {
+	for p.pos < len(p.src) {
+		switch c := p.src[p.pos]; {
+		case c == '\n':
+			p.line++
+			p.pos++
+		case c == ' ' || c == '\t' || c == '\r':
+			p.pos++
+		case c == '#':
+			for p.pos < len(p.src) && p.src[p.pos] != '\n' {
+				p.pos++
+			}
+		case c == '{' || c == '}':
+			p.pos++
+			return p.src[p.pos-1 : p.pos], nil
+		case c == '!':
+			if !strings.HasPrefix(p.src[p.pos:], "!{") {
+				return "", errors.WithMessage(ErrSyntax, "unexpected !")
+			}
+			p.pos += 2
+			return "!{", nil
+		case c == '"' || c == '`':
+			return p.quoted(c)
+		default:
+			start := p.pos
+			for p.pos < len(p.src) && !strings.ContainsRune(" \t\r\n#{}!\"`", rune(p.src[p.pos])) {
+				p.pos++
+			}
+			return p.src[start:p.pos], nil
+		}
+	}
+	return "", nil
+}
+
+// quoted returns the token quoted by the quote character, including the quotes.
+func (p *parser) quoted(quote byte) (string, error) {
+	start := p.pos
+	for p.pos++; p.pos < len(p.src); p.pos++ {
+		switch p.src[p.pos] {
+		case '\\':
+			p.pos++
+		case '\n':
+			p.line++
+		case quote:
+			p.pos++
+			return p.src[start:p.pos], nil
+		}
+	}
+	return "", errors.WithMessagef(ErrSyntax, "unterminated %c", quote)
+}
+
+// unquote decodes the quoted string, the escapes are the ones of C, and \xHH for bytes.
+func unquote(token string) ([]byte, error) {
+	s := token[1 : len(token)-1]
+	bs := make([]byte, 0, len(s))
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c != '\\' {
+			bs = append(bs, c)
+			continue
+		}
+		if i++; i >= len(s) {
+			return nil, errors.WithMessagef(ErrSyntax, "bad escape in %s", token)
+		}
+		switch c = s[i]; c {
+		case 'n':
+			bs = append(bs, '\n')
+		case 't':
+			bs = append(bs, '\t')
+		case 'r':
+			bs = append(bs, '\r')
+		case '"', '\'', '\\', '?':
+			bs = append(bs, c)
+		case 'x':
+			if i+3 > len(s) {
+				return nil, errors.WithMessagef(ErrSyntax, "bad escape in %s", token)
+			}
+			v, err := strconv.ParseUint(s[i+1:i+3], 16, 8)
+			if err != nil {
+				return nil, errors.WithMessagef(ErrSyntax, "bad escape in %s", token)
+			}
+			bs = append(bs, byte(v))
+			i += 2
+		default:
+			// octal escape of 1 to 3 digits
+			j := i
+			for j < len(s) && j < i+3 && s[j] >= '0' && s[j] <= '7' {
+				j++
+			}
+			v, err := strconv.ParseUint(s[i:j], 8, 8)
+			if j == i || err != nil {
+				return nil, errors.WithMessagef(ErrSyntax, "bad escape in %s", token)
+			}
+			bs = append(bs, byte(v))
+			i = j - 1
+		}
+	}
+	return bs, nil
+}
@@ -0,0 +1,62 @@
+package protoscope
+
+import (
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+func TestParse(t *testing.T) {
+	for text, expected := range map[string][]byte{
+		"":                     {},
+		"1: 150":               {0x08, 0x96, 0x01},
+		"1:VARINT 150":         {0x08, 0x96, 0x01},
+		"1:0 true 2: false":    {0x08, 0x01, 0x10, 0x00},
+		"2: -1":                {0x10, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x01},
+		"2: -1z 3: 0x10":       {0x10, 0x01, 0x18, 0x10},
+		"5: 1i32":              {0x2d, 0x01, 0x00, 0x00, 0x00},
+		"5: -1i32":             {0x2d, 0xff, 0xff, 0xff, 0xff},
+		"5: 1.5i32":            {0x2d, 0x00, 0x00, 0xc0, 0x3f},
+		"6: 1i64":              {0x31, 0x01, 0, 0, 0, 0, 0, 0, 0},
+		"6: 1.5":               {0x31, 0, 0, 0, 0, 0, 0, 0xf8, 0x3f},
+		`2: {"ab" 3: 1}`:       {0x12, 0x04, 'a', 'b', 0x18, 0x01},
+		"2: {`0aff`}":          {0x12, 0x02, 0x0a, 0xff},
+		`"\x00\n\"\\\101"`:     {0x00, '\n', '"', '\\', 'A'},
+		"3: !{ 1: 1 } # group": {0x1b, 0x08, 0x01, 0x1c},
+		"{ 1 2 }":              {0x02, 0x01, 0x02},
+		"3:EGROUP 1:6":         {0x1c, 0x0e},
+		"# comment\n1:\n2":     {0x08, 0x02},
+	} {
+		actual, err := Parse(text)
+		require.NoError(t, err, text)
+		require.Equal(t, expected, append([]byte{}, actual...), text)
+	}
+}
+
+func TestParseError(t *testing.T) {
+	for _, text := range []string{
+		"1: {",
+		"}",
+		"!{ 1: 2 }",
+		`1: "ab"`,
+		"1:",
+		"1:FOO 1",
+		"x",
+		"1.5z",
+		"4294967296i32",
+		"`0`",
+		`"\x1"`,
+		`"abc`,
+		"! 1",
+	} {
+		_, err := Parse(text)
+		require.True(t, errors.Is(err, ErrSyntax), "%q: %v", text, err)
+	}
+
+	_, err := Parse("1: 2\n3: {\n  x\n}")
+	require.EqualError(t, err, "line 3: bad number \"x\": protoscope syntax error")
+	require.Panics(t, func() { MustParse("x") })
+	require.Equal(t, protowire.AppendVarint(nil, 1), MustParse("1"))
+}
@@ -0,0 +1,120 @@
+package gpb
+
+import (
+	"github.com/pkg/errors"
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// Query is a compiled path, see Path syntax in path.go. The path is validated and parsed only
+// once, and the paths of field numbers only are read as GetOne and GetIter by the field numbers,
+// so it is preferred over Get and ForEachPath in hot paths. Query is immutable and safe to be
+// shared across goroutines.
+type Query struct {
+	raw  string
+	path fieldPath
+	// numbers the field numbers of the path without selectors and wildcards, nil otherwise
+	numbers []protowire.Number
+	field   protoreflect.FieldDescriptor
+	limits  Limits
+}
+
+// Compile parses the path into a reusable Query.
+func Compile(path string) (*Query, error) {
+	p, err := parsePath(path, nil, resolveFieldNumber)
+	if err != nil {
+		return nil, err
+	}
+	return &Query{raw: path, path: p, numbers: p.numbers()}, nil
+}
+
+// MustCompile is like Compile but panics if the path is invalid. It simplifies the
+// initialization of global queries.
+func MustCompile(path string) *Query {
+	q, err := Compile(path)
+	if err != nil {
+		panic(errors.WithMessage(err, "gpb: compile"))
+	}
+	return q
+}
+
+// Compile parses the name path into a reusable Query, which remembers the descriptor of the
+// last field in the path.
+func (s *Schema) Compile(path string) (*Query, error) {
+	p, leaf, err := s.compile(path, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &Query{raw: path, path: p, numbers: p.numbers(), field: leaf}, nil
+}
+
+// String returns the source path of the query.
+func (q *Query) String() string {
+	return q.raw
+}
+
+// Field returns the descriptor of the last field in the path, which is only available for
+// queries compiled by Schema.Compile, otherwise nil is returned.
+func (q *Query) Field() protoreflect.FieldDescriptor {
+	return q.field
+}
+
+// GetOne returns the first value matched in pb. When nothing is matched, a Result with
+// InvalidWireType is returned. There is no heap-memory allocation in this function.
+func (q *Query) GetOne(pb []byte) (result Result) {
+	if q.numbers != nil && q.limits == (Limits{}) {
+		return GetOne(pb, q.numbers...)
+	}
+	result.WireType = InvalidWireType
+	_ = q.Iter(pb, func(r Result) bool {
+		result = r
+		return false
+	})
+	return
+}
+
+// GetLast returns the last value matched in pb, which follows the protobuf specification for
+// non-repeated fields. See Result.GetLast for details.
+func (q *Query) GetLast(pb []byte) (result Result) {
+	result.WireType = InvalidWireType
+	_ = q.Iter(pb, func(r Result) bool {
+		result = r
+		return true
+	})
+	return
+}
+
+// GetAll returns all the values matched in pb.
+func (q *Query) GetAll(pb []byte) []Result {
+	results := make([]Result, 0)
+	_ = q.Iter(pb, func(r Result) bool {
+		results = append(results, r)
+		return true
+	})
+	return results
+}
+
+// Iter iterates through all the values matched in pb until the iterator returns false.
+func (q *Query) Iter(pb []byte, iterator func(Result) bool) error {
+	state := Result{Raw: pb}
+	if q.numbers != nil {
+		return state.getIter(newLimiter(q.limits), iterator, q.numbers...)
+	}
+	return state.walkPath(q.path, newLimiter(q.limits), iterator)
+}
+
+// numbers returns the field numbers of the path if it has no selectors or wildcards, otherwise
+// nil is returned.
+func (p fieldPath) numbers() []protowire.Number {
+	if p.count {
+		return nil
+	}
+	numbers := make([]protowire.Number, len(p.segments))
+	for i, seg := range p.segments {
+		if seg.number == anyNumber || seg.selector != selectAll {
+			return nil
+		}
+		numbers[i] = seg.number
+	}
+	return numbers
+}
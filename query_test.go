@@ -0,0 +1,108 @@
+package gpb
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/ywx217/gpb/internal/testprotos"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+func TestCompile(t *testing.T) {
+	bs := marshalRepeatedGoTest(t)
+
+	q, err := Compile("5.#1:.1")
+	require.NoError(t, err)
+	require.Equal(t, "5.#1:.1", q.String())
+	require.Nil(t, q.Field())
+	require.Equal(t, "l1", q.GetOne(bs).String())
+	require.Equal(t, "l2", q.GetLast(bs).String())
+	require.Len(t, q.GetAll(bs), 2)
+
+	var values []string
+	require.NoError(t, q.Iter(bs, func(r Result) bool {
+		values = append(values, r.String())
+		return false
+	}))
+	require.Equal(t, []string{"l1"}, values)
+
+	require.False(t, MustCompile("6.1").GetOne(bs).Exist())
+	require.Empty(t, MustCompile("6.1").GetAll(bs))
+	require.Equal(t, uint64(3), MustCompile("5.#").GetOne(bs).Uint64())
+
+	_, err = Compile("5.#x")
+	require.ErrorIs(t, err, ErrInvalidPath)
+	require.Panics(t, func() { MustCompile("") })
+}
+
+func TestCompileNumbers(t *testing.T) {
+	bs := marshalRepeatedGoTest(t)
+
+	// the paths of field numbers only are read as GetOne and GetIter
+	for _, numbers := range [][]protowire.Number{{1}, {5, 1}, {4, 2}, {70, 71}, {6, 1}} {
+		path := formatNumbers(numbers)
+		q := MustCompile(path)
+		require.Equal(t, numbers, q.numbers, path)
+		require.Equal(t, GetOne(bs, numbers...), q.GetOne(bs), path)
+		require.Equal(t, Result{Raw: bs}.GetLast(numbers...), q.GetLast(bs), path)
+		require.Equal(t, GetAll(bs, numbers...), q.GetAll(bs), path)
+		limited := q.WithOptions(Options{Limits: Limits{MaxDepth: 8}})
+		require.Equal(t, GetOne(bs, numbers...), limited.GetOne(bs), path)
+	}
+	err := MustCompile("5").WithOptions(Options{Limits: Limits{MaxFields: 1}}).Iter(bs, func(Result) bool { return true })
+	require.ErrorIs(t, err, ErrLimitExceeded)
+
+	for _, path := range []string{"5.#1.1", "*.1", "5.#"} {
+		require.Nil(t, MustCompile(path).numbers, path)
+	}
+}
+
+func TestSchemaCompile(t *testing.T) {
+	bs := marshalRepeatedGoTest(t)
+
+	q, err := goTestSchema().Compile("repeated_field.#last.label")
+	require.NoError(t, err)
+	require.Equal(t, protoreflect.FullName("proto2_test.GoTestField.Label"), q.Field().FullName())
+	require.Equal(t, "l2", q.GetOne(bs).String())
+
+	_, err = goTestSchema().Compile("repeated_field.unknown")
+	require.ErrorIs(t, err, ErrInvalidPath)
+}
+
+func TestQueryConcurrent(t *testing.T) {
+	q := MustCompile("5.#2.2")
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		msg := initGoTest(false)
+		msg.RepeatedField = []*testprotos.GoTestField{initGoTestField(), initGoTestField(), {
+			Label: proto.String("label"),
+			Type:  proto.String(string(rune('a' + i))),
+		}}
+		bs, err := proto.Marshal(msg)
+		require.NoError(t, err)
+
+		wg.Add(1)
+		go func(expected string) {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				assert.Equal(t, expected, q.GetOne(bs).String())
+			}
+		}(string(rune('a' + i)))
+	}
+	wg.Wait()
+}
+
+func TestQueryNoAlloc(t *testing.T) {
+	bs := marshalRepeatedGoTest(t)
+	q := MustCompile("5.#last.1")
+	allocs := testing.AllocsPerRun(100, func() {
+		_ = q.GetOne(bs)
+		_ = q.GetLast(bs)
+	})
+	require.Zero(t, allocs)
+}
@@ -0,0 +1,189 @@
+package gpb
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// rawRecursionLimit the max depth of the length-delimited fields decoded as messages and the groups
+// decoded as nested fields, the deeper ones are decoded as strings, which is the same as protoc for
+// the length-delimited fields.
+const rawRecursionLimit = 100
+
+// RawKind tells how a field is decoded without schema.
+type RawKind uint8
+
+const (
+	// RawScalar a varint, fixed32 or fixed64 field
+	RawScalar RawKind = iota
+	// RawMessage a length-delimited field which is decoded as a message, or the root message
+	RawMessage
+	// RawGroup a group field
+	RawGroup
+	// RawString a length-delimited field holding valid UTF-8 text, or a group nested deeper than
+	// the recursion limit whose body is valid UTF-8
+	RawString
+	// RawBytes a length-delimited field holding opaque bytes, or a group nested deeper than the
+	// recursion limit
+	RawBytes
+)
+
+// RawNode is a field decoded without schema. Messages and groups have the fields inside as the
+// children, the root node is a RawMessage with a zero Field.Number.
+type RawNode struct {
+	Field    Result
+	Kind     RawKind
+	Children []RawNode
+}
+
+// DecodeRaw decodes the message without schema, and prints it in the same layout as
+// `protoc --decode_raw`.
+func DecodeRaw(pb []byte) (string, error) {
+	root, err := ParseRaw(pb)
+	if err != nil {
+		return "", err
+	}
+	return root.String(), nil
+}
+
+// ParseRaw decodes the message without schema into a tree. A length-delimited field is decoded
+// as a message if it is not empty and holds well-formed fields only, as `protoc --decode_raw`
+// does, otherwise it is a string if it is valid UTF-8, or opaque bytes.
+func ParseRaw(pb []byte) (RawNode, error) {
+	b := rawBuilder{stack: []RawNode{{Field: Result{WireType: protowire.BytesType, Raw: pb}, Kind: RawMessage}}}
+	if err := Walk(pb, &b); err != nil {
+		return RawNode{}, err
+	}
+	return b.stack[0], nil
+}
+
+// rawBuilder is the Visitor building the RawNode tree, the top of stack is the message walked
+// through.
+type rawBuilder struct {
+	stack []RawNode
+}
+
+func (b *rawBuilder) EnterMessage(path []protowire.Number, f Result) bool {
+	if len(path) > rawRecursionLimit {
+		return false
+	}
+	kind := RawGroup
+	if f.WireType == protowire.BytesType {
+		if !isRawMessage(f.Raw) {
+			return false
+		}
+		kind = RawMessage
+	}
+	b.stack = append(b.stack, RawNode{Field: f, Kind: kind})
+	return true
+}
+
+func (b *rawBuilder) LeaveMessage(path []protowire.Number, f Result) {
+	node := b.stack[len(b.stack)-1]
+	b.stack = b.stack[:len(b.stack)-1]
+	b.append(node)
+}
+
+func (b *rawBuilder) Scalar(path []protowire.Number, f Result) {
+	kind := RawScalar
+	if f.WireType == protowire.BytesType || f.WireType == protowire.StartGroupType {
+		kind = RawBytes
+		if utf8.Valid(f.Raw) {
+			kind = RawString
+		}
+	}
+	b.append(RawNode{Field: f, Kind: kind})
+}
+
+func (b *rawBuilder) append(node RawNode) {
+	top := &b.stack[len(b.stack)-1]
+	top.Children = append(top.Children, node)
+}
+
+// isRawMessage reports whether pb is a non-empty sequence of well-formed fields, stray end group
+// tags are not allowed. The group bodies are not checked again, as an end group tag inside ends
+// the group.
+func isRawMessage(pb []byte) bool {
+	ok := len(pb) > 0
+	_, err := Result{Raw: pb}.IterFields(anyNumber, func(f Result) bool {
+		ok = f.WireType != protowire.EndGroupType
+		return ok
+	})
+	return ok && err == nil
+}
+
+// String prints the node in the same layout as `protoc --decode_raw`, the root node prints the
+// fields inside.
+func (n RawNode) String() string {
+	var sb strings.Builder
+	if n.Field.Number == 0 {
+		n.writeChildren(&sb, 0)
+	} else {
+		n.write(&sb, 0)
+	}
+	return sb.String()
+}
+
+func (n RawNode) writeChildren(sb *strings.Builder, indent int) {
+	for _, child := range n.Children {
+		child.write(sb, indent)
+	}
+}
+
+func (n RawNode) write(sb *strings.Builder, indent int) {
+	sb.WriteString(strings.Repeat("  ", indent))
+	sb.WriteString(strconv.Itoa(int(n.Field.Number)))
+	switch n.Kind {
+	case RawMessage, RawGroup:
+		sb.WriteString(" {\n")
+		n.writeChildren(sb, indent+1)
+		sb.WriteString(strings.Repeat("  ", indent))
+		sb.WriteString("}\n")
+		return
+	case RawString, RawBytes:
+		sb.WriteString(": \"")
+		writeCEscaped(sb, n.Field.Raw)
+		sb.WriteString("\"")
+	default:
+		switch n.Field.WireType {
+		case protowire.VarintType:
+			fmt.Fprintf(sb, ": %d", n.Field.Varint)
+		case protowire.Fixed32Type:
+			fmt.Fprintf(sb, ": 0x%08x", n.Field.Fixed32())
+		case protowire.Fixed64Type:
+			fmt.Fprintf(sb, ": 0x%016x", n.Field.Fixed64())
+		}
+	}
+	sb.WriteString("\n")
+}
+
+// writeCEscaped escapes the bytes as the C-style literals of protoc, the non-printable bytes
+// are escaped in octal.
+func writeCEscaped(sb *strings.Builder, bs []byte) {
+	for _, c := range bs {
+		switch c {
+		case '\n':
+			sb.WriteString(`\n`)
+		case '\r':
+			sb.WriteString(`\r`)
+		case '\t':
+			sb.WriteString(`\t`)
+		case '"':
+			sb.WriteString(`\"`)
+		case '\'':
+			sb.WriteString(`\'`)
+		case '\\':
+			sb.WriteString(`\\`)
+		default:
+			if c < 0x20 || c >= 0x7f {
+				fmt.Fprintf(sb, `\%03o`, c)
+			} else {
+				sb.WriteByte(c)
+			}
+		}
+	}
+}
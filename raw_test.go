@@ -0,0 +1,148 @@
+package gpb
+
+import (
+	"flag"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ywx217/gpb/internal/testprotos"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+)
+
+var update = flag.Bool("update", false, "update the golden files in testdata")
+
+// requireGolden compares the actual output with the golden file in testdata.
+func requireGolden(t *testing.T, name string, actual string) {
+	golden := filepath.Join("testdata", name)
+	if *update {
+		require.NoError(t, os.WriteFile(golden, []byte(actual), 0o644))
+	}
+	expected, err := os.ReadFile(golden)
+	require.NoError(t, err)
+	require.Equal(t, string(expected), actual)
+}
+
+// decodeRawInputs returns the messages of the decode_raw golden files by name, the inputs are
+// saved along with the golden files as `<name>.pb`.
+func decodeRawInputs(t *testing.T) map[string][]byte {
+	full := initGoTest(true)
+	full.RepeatedField = []*testprotos.GoTestField{initGoTestField(), initGoTestField()}
+	full.F_BoolRepeated = []bool{false, true}
+	full.F_Int32RepeatedPacked = []int32{32, -1}
+	full.F_BytesRepeated = [][]byte{[]byte("big"), {0xff, 0x00}}
+	full.Repeatedgroup = []*testprotos.GoTest_RepeatedGroup{initGoTestRepeatedGroup()}
+	inputs := make(map[string][]byte)
+	for name, msg := range map[string]proto.Message{
+		"gotest":      initGoTest(false),
+		"gotest_full": full,
+	} {
+		bs, err := proto.Marshal(msg)
+		require.NoError(t, err)
+		inputs[name] = bs
+	}
+	return inputs
+}
+
+func TestDecodeRaw(t *testing.T) {
+	for name, bs := range decodeRawInputs(t) {
+		requireGolden(t, name+".pb", string(bs))
+		text, err := DecodeRaw(bs)
+		require.NoError(t, err)
+		requireGolden(t, name+".decode_raw", text)
+	}
+
+	_, err := DecodeRaw(malformedNested()[:5])
+	require.ErrorIs(t, err, ErrInvalidLength)
+}
+
+// TestDecodeRawProtoc checks the golden files against `protoc --decode_raw`, it is skipped when
+// protoc is not installed.
+func TestDecodeRawProtoc(t *testing.T) {
+	protoc, err := exec.LookPath("protoc")
+	if err != nil {
+		t.Skip("protoc is not installed")
+	}
+	for name := range decodeRawInputs(t) {
+		input, err := os.Open(filepath.Join("testdata", name+".pb"))
+		require.NoError(t, err)
+		cmd := exec.Command(protoc, "--decode_raw")
+		cmd.Stdin = input
+		expected, err := cmd.Output()
+		require.NoError(t, input.Close())
+		require.NoError(t, err)
+		golden, err := os.ReadFile(filepath.Join("testdata", name+".decode_raw"))
+		require.NoError(t, err)
+		require.Equal(t, string(expected), string(golden), name)
+	}
+}
+
+func TestParseRaw(t *testing.T) {
+	bs, err := proto.Marshal(initGoTest(false))
+	require.NoError(t, err)
+	root, err := ParseRaw(bs)
+	require.NoError(t, err)
+	require.Equal(t, RawMessage, root.Kind)
+
+	kinds := make(map[protowire.Number]RawKind)
+	var group RawNode
+	for _, child := range root.Children {
+		kinds[child.Field.Number] = child.Kind
+		if child.Field.Number == 70 {
+			group = child
+		}
+	}
+	require.Equal(t, RawScalar, kinds[1])
+	require.Equal(t, RawMessage, kinds[4])
+	require.Equal(t, RawString, kinds[19])
+	require.Equal(t, RawGroup, kinds[70])
+	require.Equal(t, RawString, kinds[101], "bytes holding text are strings")
+
+	require.Equal(t, "70 {\n  71: \"required\"\n}\n", group.String())
+
+	pb := protowire.AppendTag(nil, 1, protowire.BytesType)
+	pb = protowire.AppendBytes(pb, []byte{0x08, 0x96, 0x01, '\n', 0xff})
+	pb = protowire.AppendTag(pb, 2, protowire.BytesType)
+	pb = protowire.AppendBytes(pb, []byte{0x0c})
+	pb = protowire.AppendTag(pb, 3, protowire.BytesType)
+	pb = protowire.AppendBytes(pb, nil)
+	root, err = ParseRaw(pb)
+	require.NoError(t, err)
+	require.Equal(t, RawBytes, root.Children[0].Kind)
+	require.Equal(t, RawString, root.Children[1].Kind, "stray end group tags are not messages")
+	require.Equal(t, RawString, root.Children[2].Kind)
+	require.Equal(t, "1: \"\\010\\226\\001\\n\\377\"\n2: \"\\014\"\n3: \"\"\n", root.String())
+}
+
+func TestDecodeRawDeepGroups(t *testing.T) {
+	pb := deepGroups(maxGroupDepth)
+	start := time.Now()
+	text, err := DecodeRaw(pb)
+	require.NoError(t, err)
+	// the groups deeper than the recursion limit are printed as strings
+	var expected strings.Builder
+	for i := 0; i < rawRecursionLimit; i++ {
+		expected.WriteString(strings.Repeat("  ", i) + "1 {\n")
+	}
+	levels := maxGroupDepth - rawRecursionLimit - 1
+	expected.WriteString(strings.Repeat("  ", rawRecursionLimit) + "1: \"" +
+		strings.Repeat(`\013`, levels) + strings.Repeat(`\014`, levels) + "\"\n")
+	for i := rawRecursionLimit - 1; i >= 0; i-- {
+		expected.WriteString(strings.Repeat("  ", i) + "}\n")
+	}
+	require.Equal(t, expected.String(), text)
+
+	// the groups in a message are nested under the same limit
+	wrapped := protowire.AppendTag(nil, 2, protowire.BytesType)
+	wrapped = protowire.AppendBytes(wrapped, pb)
+	text, err = DecodeRaw(wrapped)
+	require.NoError(t, err)
+	require.True(t, strings.HasPrefix(text, "2 {\n  1 {\n"))
+	require.Less(t, time.Since(start), deepGroupsTimeout)
+}
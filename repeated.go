@@ -0,0 +1,78 @@
+package gpb
+
+import (
+	"github.com/pkg/errors"
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// GetRepeated gets all the elements of the repeated field of the kind by the given field
+// numbers. Both the unpacked occurrences and the packed frames of scalars are accepted, even
+// mixed in one message, and the elements are returned in wire order, e.g.
+// `gpb.GetRepeated(pb, protoreflect.Sint32Kind, 502)`. The occurrences of other wire types are
+// skipped.
+func GetRepeated(pb []byte, kind protoreflect.Kind, pbNumbers ...protowire.Number) []Result {
+	state := Result{Raw: pb}
+	return state.GetRepeated(kind, pbNumbers...)
+}
+
+// GetRepeatedE like GetRepeated, but the error of a malformed message or packed frame is
+// returned instead of being discarded.
+func GetRepeatedE(pb []byte, kind protoreflect.Kind, pbNumbers ...protowire.Number) ([]Result, error) {
+	state := Result{Raw: pb}
+	return state.GetRepeatedE(kind, pbNumbers...)
+}
+
+// GetRepeated gets all the elements of the repeated field, see GetRepeated for details.
+func (r Result) GetRepeated(kind protoreflect.Kind, pbNumbers ...protowire.Number) []Result {
+	results, _ := r.GetRepeatedE(kind, pbNumbers...)
+	return results
+}
+
+// GetRepeatedE like GetRepeated, but the error of a malformed message or packed frame is
+// returned, along with the elements found before the error occurred.
+func (r Result) GetRepeatedE(kind protoreflect.Kind, pbNumbers ...protowire.Number) ([]Result, error) {
+	itemType := wireTypeOf(kind)
+	if itemType == InvalidWireType {
+		return nil, errors.WithMessagef(ErrUnknownWireType, "unknown kind %v", kind)
+	}
+	results := make([]Result, 0)
+	var unpackErr error
+	err := r.GetIter(func(field Result) bool {
+		results, unpackErr = appendElements(results, field, itemType)
+		return unpackErr == nil
+	}, pbNumbers...)
+	if err == nil && unpackErr != nil {
+		err = enclose(unpackErr, pbNumbers[:len(pbNumbers)-1]...)
+	}
+	return results, err
+}
+
+// appendElements appends the elements of the field to results, the packed frames of scalars
+// are unpacked.
+func appendElements(results []Result, field Result, itemType protowire.Type) ([]Result, error) {
+	switch {
+	case field.WireType == itemType:
+		return append(results, field), nil
+	case field.WireType != protowire.BytesType || itemType == protowire.StartGroupType:
+		return results, nil
+	}
+	start := len(results)
+	results = append(results, field.Unpack(itemType)...)
+	end := field.rawStart()
+	if len(results) > start {
+		end = results[len(results)-1].End
+	}
+	if end != field.rawStart()+len(field.Raw) {
+		// the frame holds no fields, so the ParseError is located at the malformed element without
+		// the fields before it scanned by newParseError
+		pe := &ParseError{
+			Number:   field.Number,
+			WireType: protowire.BytesType,
+			Err:      errors.WithMessage(ErrInvalidLength, "malformed packed frame"),
+			at:       field.Raw[end-field.rawStart():],
+		}
+		return results, locate(pe, field)
+	}
+	return results, nil
+}
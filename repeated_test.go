@@ -0,0 +1,89 @@
+package gpb
+
+import (
+	"testing"
+
+	"github.com/ywx217/gpb/internal/testprotos"
+	"github.com/ywx217/gpb/protoscope"
+
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+func TestGetRepeated(t *testing.T) {
+	msg := initGoTest(false)
+	msg.F_Int32Repeated = []int32{32, 33, 34}
+	msg.F_Int32RepeatedPacked = []int32{51, -52}
+	msg.F_FloatRepeatedPacked = []float32{1.5, 2.5}
+	msg.F_Sfixed64Repeated = []int64{-1, 2}
+	msg.F_StringRepeated = []string{"a", "b"}
+	msg.Repeatedgroup = []*testprotos.GoTest_RepeatedGroup{initGoTestRepeatedGroup(), initGoTestRepeatedGroup()}
+	bs, err := proto.Marshal(msg)
+	require.NoError(t, err)
+
+	int32s := func(results []Result) []int32 {
+		return lo.Map(results, func(r Result, _ int) int32 { return r.Int32() })
+	}
+	require.Equal(t, []int32{32, 33, 34}, int32s(GetRepeated(bs, protoreflect.Int32Kind, 21)))
+	require.Equal(t, []int32{51, -52}, int32s(GetRepeated(bs, protoreflect.Int32Kind, 51)))
+	require.Equal(t, []float32{1.5, 2.5}, lo.Map(GetRepeated(bs, protoreflect.FloatKind, 57), func(r Result, _ int) float32 {
+		return r.Float32()
+	}))
+	require.Equal(t, []int64{-1, 2}, lo.Map(GetRepeated(bs, protoreflect.Sfixed64Kind, 205), func(r Result, _ int) int64 {
+		return r.SFixed64()
+	}))
+	require.Equal(t, []string{"a", "b"}, lo.Map(GetRepeated(bs, protoreflect.StringKind, 29), func(r Result, _ int) string {
+		return r.String()
+	}))
+	require.Len(t, GetRepeated(bs, protoreflect.GroupKind, 80), 2)
+	require.Empty(t, GetRepeated(bs, protoreflect.Int32Kind, 22))
+	require.Equal(t, "label", GetRepeated(bs, protoreflect.StringKind, 4, 1)[0].String())
+
+	// the offsets of the elements
+	for _, r := range GetRepeated(bs, protoreflect.Int32Kind, 51) {
+		require.Equal(t, r.Raw, bs[r.Start:r.End])
+	}
+}
+
+func TestGetRepeatedMixed(t *testing.T) {
+	// packed frames and unpacked occurrences in wire order, other wire types are skipped
+	pb := protoscope.MustParse(`1: 1 2: 0 1: {2 3} 1: 4 1: 5i32 1: {} 1: {6}`)
+	results, err := GetRepeatedE(pb, protoreflect.Sint64Kind, 1)
+	require.NoError(t, err)
+	require.Equal(t, []int64{-1, 1, -2, 2, 3}, lo.Map(results, func(r Result, _ int) int64 { return r.Sint64() }))
+
+	results, err = GetRepeatedE(protoscope.MustParse(`1: 1i32 1: {2i32 3i32} 1: 4`), protoreflect.Fixed32Kind, 1)
+	require.NoError(t, err)
+	require.Equal(t, []uint32{1, 2, 3}, lo.Map(results, func(r Result, _ int) uint32 { return r.Fixed32() }))
+}
+
+func TestGetRepeatedError(t *testing.T) {
+	// malformed packed frames
+	pb := protoscope.MustParse(`1: 1 1: {2 ` + "`80`" + `} 1: 3`)
+	results, err := GetRepeatedE(pb, protoreflect.Int32Kind, 1)
+	require.ErrorIs(t, err, ErrInvalidLength)
+	require.Equal(t, []int32{1, 2}, lo.Map(results, func(r Result, _ int) int32 { return r.Int32() }))
+	require.Len(t, GetRepeated(pb, protoreflect.Int32Kind, 1), 2)
+	var pe *ParseError
+	require.ErrorAs(t, err, &pe)
+	require.Equal(t, "offset=5 field=1 wire_type=2: malformed packed frame: invalid length", err.Error())
+
+	// the offsets are in the root buffer, the path is of the enclosing messages
+	nested := protoscope.MustParse(`2: 2 4: {1: {2 ` + "`80`" + `}}`)
+	_, err = GetRepeatedE(nested, protoreflect.Int32Kind, 4, 1)
+	require.Equal(t, "offset=7 path=4 field=1 wire_type=2: malformed packed frame: invalid length", err.Error())
+	_, err = GetOne(nested, 4).GetRepeatedE(protoreflect.Int32Kind, 1)
+	require.Equal(t, "offset=7 field=1 wire_type=2: malformed packed frame: invalid length", err.Error())
+
+	_, err = GetRepeatedE(protoscope.MustParse(`1: {1i32 `+"`02`"+`}`), protoreflect.FloatKind, 1)
+	require.ErrorIs(t, err, ErrInvalidLength)
+
+	// malformed messages
+	_, err = GetRepeatedE(protoscope.MustParse(`1: 1 `+"`0a05`"), protoreflect.Int32Kind, 1)
+	require.ErrorAs(t, err, &pe)
+
+	_, err = GetRepeatedE(pb, protoreflect.Kind(0), 1)
+	require.ErrorIs(t, err, ErrUnknownWireType)
+}
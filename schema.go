@@ -0,0 +1,301 @@
+package gpb
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+// Schema resolves paths of field names into paths of field numbers with a message descriptor,
+// and converts the results into typed values according to the kinds of the fields.
+//
+// Name paths share the syntax of number paths, except that each field segment can be either a
+// field number or a field name, and the wildcard `*` and the count `#` are not supported.
+// A field name matches the proto name, the json name or the text name of a field, and at last
+// a case-insensitive comparison with underscores ignored, e.g. `required_field.label`,
+// `RequiredField.Label` and `4.1` are the same path of GoTest.
+//
+// Schema is immutable and safe to be shared across goroutines.
+type Schema struct {
+	desc protoreflect.MessageDescriptor
+}
+
+// NewSchema creates a schema of the message descriptor.
+func NewSchema(desc protoreflect.MessageDescriptor) *Schema {
+	return &Schema{desc: desc}
+}
+
+// NewSchemaFromFileDescriptorSet creates a schema of the message with the given full name,
+// which is defined in the file descriptor set.
+func NewSchemaFromFileDescriptorSet(set *descriptorpb.FileDescriptorSet, message protoreflect.FullName) (*Schema, error) {
+	files, err := protodesc.NewFiles(set)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid file descriptor set")
+	}
+	desc, err := files.FindDescriptorByName(message)
+	if err != nil {
+		return nil, errors.Wrapf(err, "message %s", message)
+	}
+	msg, ok := desc.(protoreflect.MessageDescriptor)
+	if !ok {
+		return nil, errors.Errorf("%s is not a message", message)
+	}
+	return NewSchema(msg), nil
+}
+
+// ParseSchema creates a schema from the binary file descriptor set, which is produced by
+// `protoc --include_imports -o <file>`.
+func ParseSchema(raw []byte, message protoreflect.FullName) (*Schema, error) {
+	var set descriptorpb.FileDescriptorSet
+	if err := proto.Unmarshal(raw, &set); err != nil {
+		return nil, errors.Wrap(err, "invalid file descriptor set")
+	}
+	return NewSchemaFromFileDescriptorSet(&set, message)
+}
+
+// Descriptor returns the message descriptor of the schema.
+func (s *Schema) Descriptor() protoreflect.MessageDescriptor {
+	return s.desc
+}
+
+// Resolve translates the path of field names into the path of field numbers, e.g.
+// `required_field.label` into `4.1`, and returns the descriptor of the last field.
+func (s *Schema) Resolve(path string) (string, protoreflect.FieldDescriptor, error) {
+	p, leaf, err := s.compile(path, nil)
+	if err != nil {
+		return "", nil, err
+	}
+	var sb strings.Builder
+	for i, seg := range p.segments {
+		if i > 0 {
+			sb.WriteByte(pathSeparator)
+		}
+		sb.WriteString(strconv.Itoa(int(seg.number)))
+		if seg.selector != selectAll {
+			sb.WriteByte(pathSeparator)
+			sb.WriteByte(pathSelector)
+		}
+		switch seg.selector {
+		case selectIndex:
+			sb.WriteString(strconv.Itoa(seg.index))
+		case selectLast:
+			sb.WriteString(pathLast)
+		case selectRange:
+			if seg.index > 0 {
+				sb.WriteString(strconv.Itoa(seg.index))
+			}
+			sb.WriteByte(pathRange)
+			if seg.end >= 0 {
+				sb.WriteString(strconv.Itoa(seg.end))
+			}
+		}
+	}
+	return sb.String(), leaf, nil
+}
+
+// Get searches pb for the given name path, and returns the first value matched. When nothing
+// is matched or the path is invalid, a TypedResult with InvalidWireType is returned.
+func (s *Schema) Get(pb []byte, path string) (result TypedResult) {
+	result.WireType = InvalidWireType
+	_ = s.ForEachPath(pb, path, func(r TypedResult) bool {
+		result = r
+		return false
+	})
+	return
+}
+
+// ForEachPath iterates through all the values matched by the given name path until the
+// iterator returns false.
+func (s *Schema) ForEachPath(pb []byte, path string, iterator func(TypedResult) bool) error {
+	var buf [inlinePathSegments]pathSegment
+	p, leaf, err := s.compile(path, buf[:0])
+	if err != nil {
+		return err
+	}
+	state := Result{Raw: pb}
+	return state.walkPath(p, nil, func(r Result) bool {
+		return iterator(TypedResult{Result: r, Desc: leaf})
+	})
+}
+
+// ForEachValue iterates through all the typed values matched by the given name path until the
+// iterator returns false. Both the packed and unpacked repeated scalars are iterated value by
+// value.
+func (s *Schema) ForEachValue(pb []byte, path string, iterator func(protoreflect.Value) bool) error {
+	return s.ForEachPath(pb, path, func(r TypedResult) bool {
+		for _, v := range r.Values() {
+			if !iterator(v) {
+				return false
+			}
+		}
+		return true
+	})
+}
+
+// compile parses the name path into segments appended to the given slice.
+func (s *Schema) compile(path string, segments []pathSegment) (fieldPath, protoreflect.FieldDescriptor, error) {
+	msg := s.desc
+	var leaf protoreflect.FieldDescriptor
+	p, err := parsePath(path, segments, func(token string) (protowire.Number, error) {
+		if msg == nil {
+			return 0, errors.WithMessagef(ErrInvalidPath, "%s is not a message", leaf.FullName())
+		}
+		fd := findField(msg, token)
+		if fd == nil {
+			return 0, errors.WithMessagef(ErrInvalidPath, "field %q not found in %s", token, msg.FullName())
+		}
+		leaf = fd
+		msg = fd.Message()
+		return fd.Number(), nil
+	})
+	if err != nil {
+		return p, nil, err
+	}
+	if p.count {
+		return p, nil, errors.WithMessagef(ErrInvalidPath, "count is not supported by schema, path=%q", path)
+	}
+	return p, leaf, nil
+}
+
+// findField finds the field of the message by number or by name, nil is returned if not found.
+func findField(msg protoreflect.MessageDescriptor, token string) protoreflect.FieldDescriptor {
+	fields := msg.Fields()
+	if number, err := strconv.ParseInt(token, 10, 32); err == nil {
+		return fields.ByNumber(protowire.Number(number))
+	}
+	if fd := fields.ByName(protoreflect.Name(token)); fd != nil {
+		return fd
+	}
+	if fd := fields.ByJSONName(token); fd != nil {
+		return fd
+	}
+	if fd := fields.ByTextName(token); fd != nil {
+		return fd
+	}
+	var found protoreflect.FieldDescriptor
+	normalized := normalizeName(token)
+	for i := 0; i < fields.Len(); i++ {
+		if fd := fields.Get(i); normalizeName(string(fd.Name())) == normalized {
+			if found != nil {
+				// ambiguous name
+				return nil
+			}
+			found = fd
+		}
+	}
+	return found
+}
+
+func normalizeName(name string) string {
+	return strings.ToLower(strings.ReplaceAll(name, "_", ""))
+}
+
+// wireTypeOf returns the wire type of the scalar values of the given kind.
+func wireTypeOf(kind protoreflect.Kind) protowire.Type {
+	switch kind {
+	case protoreflect.BoolKind, protoreflect.EnumKind,
+		protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Uint32Kind,
+		protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Uint64Kind:
+		return protowire.VarintType
+	case protoreflect.Fixed32Kind, protoreflect.Sfixed32Kind, protoreflect.FloatKind:
+		return protowire.Fixed32Type
+	case protoreflect.Fixed64Kind, protoreflect.Sfixed64Kind, protoreflect.DoubleKind:
+		return protowire.Fixed64Type
+	case protoreflect.StringKind, protoreflect.BytesKind, protoreflect.MessageKind:
+		return protowire.BytesType
+	case protoreflect.GroupKind:
+		return protowire.StartGroupType
+	default:
+		return InvalidWireType
+	}
+}
+
+// TypedResult is a Result with the descriptor of the field it belongs to.
+type TypedResult struct {
+	Result
+	Desc protoreflect.FieldDescriptor
+}
+
+// Value converts the result into a value of the field kind with the Result accessors, e.g.
+// Sint32 is used for sint32 fields and Int32 is used for int32 fields. Embedded messages and
+// groups are returned as raw bytes. An invalid value is returned for non-existing results.
+func (r TypedResult) Value() protoreflect.Value {
+	if !r.Exist() || r.Desc == nil {
+		return protoreflect.Value{}
+	}
+	switch r.Desc.Kind() {
+	case protoreflect.BoolKind:
+		return protoreflect.ValueOfBool(r.Bool())
+	case protoreflect.EnumKind:
+		return protoreflect.ValueOfEnum(protoreflect.EnumNumber(r.Int32()))
+	case protoreflect.Int32Kind:
+		return protoreflect.ValueOfInt32(r.Int32())
+	case protoreflect.Sint32Kind:
+		return protoreflect.ValueOfInt32(r.Sint32())
+	case protoreflect.Uint32Kind:
+		return protoreflect.ValueOfUint32(r.Uint32())
+	case protoreflect.Int64Kind:
+		return protoreflect.ValueOfInt64(r.Int64())
+	case protoreflect.Sint64Kind:
+		return protoreflect.ValueOfInt64(r.Sint64())
+	case protoreflect.Uint64Kind:
+		return protoreflect.ValueOfUint64(r.Uint64())
+	case protoreflect.Fixed32Kind:
+		return protoreflect.ValueOfUint32(r.Fixed32())
+	case protoreflect.Sfixed32Kind:
+		return protoreflect.ValueOfInt32(r.SFixed32())
+	case protoreflect.FloatKind:
+		return protoreflect.ValueOfFloat32(r.Float32())
+	case protoreflect.Fixed64Kind:
+		return protoreflect.ValueOfUint64(r.Fixed64())
+	case protoreflect.Sfixed64Kind:
+		return protoreflect.ValueOfInt64(r.SFixed64())
+	case protoreflect.DoubleKind:
+		return protoreflect.ValueOfFloat64(r.Float64())
+	case protoreflect.StringKind:
+		return protoreflect.ValueOfString(r.String())
+	case protoreflect.BytesKind:
+		return protoreflect.ValueOfBytes(r.Bytes())
+	case protoreflect.MessageKind, protoreflect.GroupKind:
+		return protoreflect.ValueOfBytes(r.Raw)
+	default:
+		return protoreflect.Value{}
+	}
+}
+
+// Values converts the result into values of the field kind. A packed frame of a repeated
+// scalar field is unpacked into multiple values, otherwise a single value is returned.
+func (r TypedResult) Values() []protoreflect.Value {
+	if !r.Exist() || r.Desc == nil {
+		return nil
+	}
+	itemType := wireTypeOf(r.Desc.Kind())
+	if !r.Desc.IsList() || r.WireType != protowire.BytesType || itemType == protowire.BytesType {
+		return []protoreflect.Value{r.Value()}
+	}
+	items := r.Unpack(itemType)
+	values := make([]protoreflect.Value, len(items))
+	for i, item := range items {
+		values[i] = TypedResult{Result: item, Desc: r.Desc}.Value()
+	}
+	return values
+}
+
+// EnumName returns the name of the enum value, empty string is returned when the field is not
+// an enum or the value is not defined.
+func (r TypedResult) EnumName() protoreflect.Name {
+	if !r.Exist() || r.Desc == nil || r.Desc.Enum() == nil {
+		return ""
+	}
+	v := r.Desc.Enum().Values().ByNumber(protoreflect.EnumNumber(r.Int32()))
+	if v == nil {
+		return ""
+	}
+	return v.Name()
+}
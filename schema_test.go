@@ -0,0 +1,105 @@
+package gpb
+
+import (
+	"testing"
+
+	"github.com/ywx217/gpb/internal/testprotos"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+func goTestSchema() *Schema {
+	return NewSchema((&testprotos.GoTest{}).ProtoReflect().Descriptor())
+}
+
+func TestSchemaResolve(t *testing.T) {
+	schema := goTestSchema()
+	for path, expected := range map[string]string{
+		"required_field.label":         "4.1",
+		"RequiredField.Label":          "4.1",
+		"requiredField.type":           "4.2",
+		"4.label":                      "4.1",
+		"repeated_field.#2.label":      "5.#2.1",
+		"repeated_field.#last.1":       "5.#last.1",
+		"repeated_field.#1:.type":      "5.#1:.2",
+		"repeated_field.#:3.type":      "5.#:3.2",
+		"requiredgroup.RequiredField":  "70.71",
+		"RequiredGroup.required_field": "70.71",
+		"F_Sint32_required":            "102",
+		"f_sint32_required":            "102",
+	} {
+		numbers, _, err := schema.Resolve(path)
+		require.NoError(t, err, path)
+		require.Equal(t, expected, numbers, path)
+	}
+
+	for _, path := range []string{"", "unknown", "kind.label", "required_field.#", "*.label", "required_field.unknown", "3000"} {
+		_, _, err := schema.Resolve(path)
+		require.ErrorIs(t, err, ErrInvalidPath, path)
+	}
+
+	_, leaf, err := schema.Resolve("required_field.label")
+	require.NoError(t, err)
+	require.Equal(t, protoreflect.FullName("proto2_test.GoTestField.Label"), leaf.FullName())
+}
+
+func TestSchemaGet(t *testing.T) {
+	msg := initGoTest(true)
+	msg.F_Sint32RepeatedPacked = []int32{32, -32}
+	msg.F_Sint32Repeated = []int32{1, -1}
+	bs, err := proto.Marshal(msg)
+	require.NoError(t, err)
+	schema := goTestSchema()
+
+	require.Equal(t, "label", schema.Get(bs, "required_field.label").Value().String())
+	require.Equal(t, int64(-32), schema.Get(bs, "F_Sint32_required").Value().Int())
+	require.Equal(t, int64(-64), schema.Get(bs, "F_Sfixed64_required").Value().Int())
+	require.Equal(t, uint64(6464), schema.Get(bs, "F_Uint64_required").Value().Uint())
+	require.Equal(t, float32(3232), float32(schema.Get(bs, "F_Float_required").Value().Float()))
+	require.Equal(t, true, schema.Get(bs, "F_Bool_required").Value().Bool())
+	require.Equal(t, []byte("bytes"), schema.Get(bs, "F_Bytes_required").Value().Bytes())
+	require.Equal(t, protoreflect.EnumNumber(testprotos.GoTest_TIME), schema.Get(bs, "kind").Value().Enum())
+	require.Equal(t, protoreflect.Name("TIME"), schema.Get(bs, "kind").EnumName())
+	require.Equal(t, protoreflect.Name(""), schema.Get(bs, "required_field.label").EnumName())
+	require.Equal(t, "required", GetOne(schema.Get(bs, "requiredgroup").Value().Bytes(), 71).String())
+
+	missing := schema.Get(bs, "optional_field.label")
+	require.False(t, missing.Exist())
+	require.False(t, missing.Value().IsValid())
+
+	var values []int64
+	for _, path := range []string{"F_Sint32_repeated_packed", "F_Sint32_repeated"} {
+		require.NoError(t, schema.ForEachValue(bs, path, func(v protoreflect.Value) bool {
+			values = append(values, v.Int())
+			return true
+		}))
+	}
+	require.Equal(t, []int64{32, -32, 1, -1}, values)
+	require.ErrorIs(t, schema.ForEachValue(bs, "unknown", func(protoreflect.Value) bool { return true }), ErrInvalidPath)
+}
+
+func TestParseSchema(t *testing.T) {
+	set := &descriptorpb.FileDescriptorSet{
+		File: []*descriptorpb.FileDescriptorProto{protodesc.ToFileDescriptorProto(testprotos.File_test_proto)},
+	}
+	raw, err := proto.Marshal(set)
+	require.NoError(t, err)
+
+	schema, err := ParseSchema(raw, "proto2_test.GoTest")
+	require.NoError(t, err)
+	require.Equal(t, protoreflect.FullName("proto2_test.GoTest"), schema.Descriptor().FullName())
+	numbers, _, err := schema.Resolve("required_field.label")
+	require.NoError(t, err)
+	require.Equal(t, "4.1", numbers)
+
+	_, err = ParseSchema(raw, "proto2_test.Unknown")
+	require.Error(t, err)
+	_, err = ParseSchema(raw, "proto2_test.FOO")
+	require.Error(t, err)
+	_, err = ParseSchema([]byte{0xff}, "proto2_test.GoTest")
+	require.Error(t, err)
+}
@@ -0,0 +1,126 @@
+package gpb
+
+import (
+	"math"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// Value is a field value to be written, created by the ValueOf functions. The constructors
+// mirror the accessors of Result, e.g. a value read by Result.Sint32 is written with
+// ValueOfSint32.
+type Value struct {
+	wireType protowire.Type
+	// scalar the varint, or the bits of fixed32 and fixed64
+	scalar uint64
+	// raw the content of length-delimited fields and the body of groups
+	raw []byte
+}
+
+func ValueOfInt32(v int32) Value {
+	return Value{wireType: protowire.VarintType, scalar: uint64(int64(v))}
+}
+
+func ValueOfInt64(v int64) Value {
+	return Value{wireType: protowire.VarintType, scalar: uint64(v)}
+}
+
+func ValueOfUint32(v uint32) Value {
+	return Value{wireType: protowire.VarintType, scalar: uint64(v)}
+}
+
+func ValueOfUint64(v uint64) Value {
+	return Value{wireType: protowire.VarintType, scalar: v}
+}
+
+func ValueOfBool(v bool) Value {
+	return Value{wireType: protowire.VarintType, scalar: protowire.EncodeBool(v)}
+}
+
+func ValueOfSint32(v int32) Value {
+	return Value{wireType: protowire.VarintType, scalar: protowire.EncodeZigZag(int64(v))}
+}
+
+func ValueOfSint64(v int64) Value {
+	return Value{wireType: protowire.VarintType, scalar: protowire.EncodeZigZag(v)}
+}
+
+func ValueOfFloat32(v float32) Value {
+	return Value{wireType: protowire.Fixed32Type, scalar: uint64(math.Float32bits(v))}
+}
+
+func ValueOfFixed32(v uint32) Value {
+	return Value{wireType: protowire.Fixed32Type, scalar: uint64(v)}
+}
+
+func ValueOfSFixed32(v int32) Value {
+	return Value{wireType: protowire.Fixed32Type, scalar: uint64(uint32(v))}
+}
+
+func ValueOfFloat64(v float64) Value {
+	return Value{wireType: protowire.Fixed64Type, scalar: math.Float64bits(v)}
+}
+
+func ValueOfFixed64(v uint64) Value {
+	return Value{wireType: protowire.Fixed64Type, scalar: v}
+}
+
+func ValueOfSFixed64(v int64) Value {
+	return Value{wireType: protowire.Fixed64Type, scalar: uint64(v)}
+}
+
+func ValueOfString(v string) Value {
+	return Value{wireType: protowire.BytesType, raw: []byte(v)}
+}
+
+// ValueOfBytes creates a length-delimited value, which is also used for embedded messages and
+// packed repeated fields.
+func ValueOfBytes(v []byte) Value {
+	return Value{wireType: protowire.BytesType, raw: v}
+}
+
+// ValueOfGroup creates a group value of the encoded fields, the tags of the group are written
+// around it.
+func ValueOfGroup(fields []byte) Value {
+	return Value{wireType: protowire.StartGroupType, raw: fields}
+}
+
+// ValueOfResult creates a value of the same wire type and content as the result, so that fields
+// can be copied between messages. An invalid value is returned for non-existing results.
+func ValueOfResult(r Result) Value {
+	switch r.WireType {
+	case protowire.VarintType:
+		return ValueOfUint64(r.Varint)
+	case protowire.Fixed32Type:
+		return ValueOfFixed32(r.Fixed32())
+	case protowire.Fixed64Type:
+		return ValueOfFixed64(r.Fixed64())
+	case protowire.BytesType, protowire.StartGroupType:
+		return Value{wireType: r.WireType, raw: r.Raw}
+	default:
+		return Value{wireType: InvalidWireType}
+	}
+}
+
+// WireType returns the wire type of the value.
+func (v Value) WireType() protowire.Type {
+	return v.wireType
+}
+
+// appendField appends the field of the value to dst.
+func (v Value) appendField(dst []byte, number protowire.Number) []byte {
+	dst = protowire.AppendTag(dst, number, v.wireType)
+	switch v.wireType {
+	case protowire.VarintType:
+		return protowire.AppendVarint(dst, v.scalar)
+	case protowire.Fixed32Type:
+		return protowire.AppendFixed32(dst, uint32(v.scalar))
+	case protowire.Fixed64Type:
+		return protowire.AppendFixed64(dst, v.scalar)
+	case protowire.BytesType:
+		return protowire.AppendBytes(dst, v.raw)
+	default:
+		dst = append(dst, v.raw...)
+		return protowire.AppendTag(dst, number, protowire.EndGroupType)
+	}
+}
@@ -0,0 +1,39 @@
+package gpb
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+func TestValueOf(t *testing.T) {
+	read := func(v Value) Result {
+		pb := v.appendField(nil, 1)
+		return GetOne(pb, 1)
+	}
+	require.Equal(t, int32(-1), read(ValueOfInt32(-1)).Int32())
+	require.Equal(t, int64(math.MinInt64), read(ValueOfInt64(math.MinInt64)).Int64())
+	require.Equal(t, uint32(math.MaxUint32), read(ValueOfUint32(math.MaxUint32)).Uint32())
+	require.Equal(t, uint64(math.MaxUint64), read(ValueOfUint64(math.MaxUint64)).Uint64())
+	require.True(t, read(ValueOfBool(true)).Bool())
+	require.Equal(t, int32(-32), read(ValueOfSint32(-32)).Sint32())
+	require.Equal(t, int64(-64), read(ValueOfSint64(-64)).Sint64())
+	require.Equal(t, float32(1.5), read(ValueOfFloat32(1.5)).Float32())
+	require.Equal(t, uint32(32), read(ValueOfFixed32(32)).Fixed32())
+	require.Equal(t, int32(-32), read(ValueOfSFixed32(-32)).SFixed32())
+	require.Equal(t, 1.5, read(ValueOfFloat64(1.5)).Float64())
+	require.Equal(t, uint64(64), read(ValueOfFixed64(64)).Fixed64())
+	require.Equal(t, int64(-64), read(ValueOfSFixed64(-64)).SFixed64())
+	require.Equal(t, "s", read(ValueOfString("s")).String())
+	require.Equal(t, []byte{0xff}, read(ValueOfBytes([]byte{0xff})).Bytes())
+	require.Equal(t, "s", read(ValueOfGroup([]byte{0x0a, 0x01, 's'})).GetOne(1).String())
+
+	require.Equal(t, protowire.VarintType, ValueOfInt32(1).WireType())
+	require.Equal(t, protowire.StartGroupType, ValueOfGroup(nil).WireType())
+	for _, v := range []Value{ValueOfInt32(-1), ValueOfFixed32(1), ValueOfSFixed64(-1), ValueOfString("s"), ValueOfGroup([]byte{0x08, 0x01})} {
+		require.Equal(t, v, ValueOfResult(read(v)))
+	}
+	require.Equal(t, InvalidWireType, ValueOfResult(GetOne(nil, 1)).WireType())
+}
@@ -0,0 +1,74 @@
+package gpb
+
+import "google.golang.org/protobuf/encoding/protowire"
+
+// inlineWalkDepth the depth of the number paths walked through without allocations
+const inlineWalkDepth = 16
+
+// Visitor receives the events of Walk in the order of the fields in the message. The path is the
+// field numbers from the root message to the current field, it is reused during the walk and must
+// be copied to be retained.
+type Visitor interface {
+	// EnterMessage is called for each length-delimited field and group, returns true to descend
+	// into it as a message, otherwise the field is passed to Scalar as opaque bytes.
+	EnterMessage(path []protowire.Number, f Result) bool
+	// LeaveMessage is called after all the fields of a message entered are visited.
+	LeaveMessage(path []protowire.Number, f Result)
+	// Scalar is called for each field not descended into.
+	Scalar(path []protowire.Number, f Result)
+}
+
+// Walk walks through the message tree in depth first order, the visitor decides whether the
+// length-delimited fields are descended into. Groups are walked through as nested messages.
+func Walk(pb []byte, visitor Visitor) error {
+	return Result{Raw: pb}.Walk(visitor)
+}
+
+// Walk walks through the message tree of the result, see Walk.
+func (r Result) Walk(visitor Visitor) error {
+	return r.walk(nil, visitor)
+}
+
+func (r Result) walk(l *limiter, visitor Visitor) error {
+	if l == nil {
+		l = &limiter{Limits: Limits{MaxGroupDepth: maxGroupDepth}}
+	}
+	// the nested groups are descended into level by level, so their lengths are cached
+	l.cacheGroups = true
+	var buf [inlineWalkDepth]protowire.Number
+	w := treeWalker{visitor: visitor, limits: l, path: buf[:0]}
+	return locate(w.walk(r), r)
+}
+
+type treeWalker struct {
+	visitor Visitor
+	limits  *limiter
+	path    []protowire.Number
+}
+
+func (w *treeWalker) walk(msg Result) error {
+	var err error
+	_, iterErr := msg.iterFields(w.limits, len(w.path), anyNumber, func(field Result) bool {
+		if field.WireType == protowire.EndGroupType {
+			// a stray end group tag is not a field
+			return true
+		}
+		w.path = append(w.path, field.Number)
+		if (field.WireType == protowire.BytesType || field.WireType == protowire.StartGroupType) &&
+			w.visitor.EnterMessage(w.path, field) {
+			if err = w.walk(field); err != nil {
+				err = enclose(err, field.Number)
+				return false
+			}
+			w.visitor.LeaveMessage(w.path, field)
+		} else {
+			w.visitor.Scalar(w.path, field)
+		}
+		w.path = w.path[:len(w.path)-1]
+		return true
+	})
+	if iterErr != nil {
+		return iterErr
+	}
+	return err
+}
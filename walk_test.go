@@ -0,0 +1,175 @@
+package gpb
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ywx217/gpb/protoscope"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+)
+
+// walkEvent is an event of the visitor, identified by the path, the field number and the wire type.
+type walkEvent struct {
+	kind     string
+	path     string
+	number   protowire.Number
+	wireType protowire.Type
+	raw      string
+}
+
+// recordVisitor records the events, the fields in messages are descended into.
+type recordVisitor struct {
+	messages map[protowire.Number]bool
+	events   []walkEvent
+}
+
+func (v *recordVisitor) EnterMessage(path []protowire.Number, f Result) bool {
+	if !v.messages[f.Number] {
+		return false
+	}
+	v.events = append(v.events, walkEvent{"enter", formatNumbers(path), f.Number, f.WireType, ""})
+	return true
+}
+
+func (v *recordVisitor) LeaveMessage(path []protowire.Number, f Result) {
+	v.events = append(v.events, walkEvent{"leave", formatNumbers(path), f.Number, f.WireType, ""})
+}
+
+func (v *recordVisitor) Scalar(path []protowire.Number, f Result) {
+	v.events = append(v.events, walkEvent{"scalar", formatNumbers(path), f.Number, f.WireType, string(f.Raw)})
+}
+
+// under returns the events of the field and the fields inside it, in the order visited.
+func (v *recordVisitor) under(number protowire.Number) []walkEvent {
+	prefix := formatNumbers([]protowire.Number{number})
+	var events []walkEvent
+	for _, e := range v.events {
+		if e.path == prefix || strings.HasPrefix(e.path, prefix+string(pathSeparator)) {
+			events = append(events, e)
+		}
+	}
+	return events
+}
+
+func TestWalk(t *testing.T) {
+	msg := initGoTest(false)
+	msg.RequiredField.Label = proto.String("4.1")
+	msg.Requiredgroup.RequiredField = proto.String("70.71")
+	bs, err := proto.Marshal(msg)
+	require.NoError(t, err)
+
+	v := &recordVisitor{messages: map[protowire.Number]bool{4: true, 70: true}}
+	require.NoError(t, Walk(bs, v))
+	require.Equal(t, []walkEvent{{"scalar", "19", 19, protowire.BytesType, "string"}}, v.under(19),
+		"strings are not descended into")
+	require.Equal(t, []walkEvent{
+		{"enter", "4", 4, protowire.BytesType, ""},
+		{"scalar", "4.1", 1, protowire.BytesType, "4.1"},
+		{"scalar", "4.2", 2, protowire.BytesType, "type"},
+		{"leave", "4", 4, protowire.BytesType, ""},
+	}, v.under(4))
+	require.Equal(t, []walkEvent{
+		{"enter", "70", 70, protowire.StartGroupType, ""},
+		{"scalar", "70.71", 71, protowire.BytesType, "70.71"},
+		{"leave", "70", 70, protowire.StartGroupType, ""},
+	}, v.under(70))
+
+	// the group is opaque if not entered
+	v = &recordVisitor{}
+	require.NoError(t, GetOne(bs, 4).Walk(v))
+	require.Equal(t, []walkEvent{
+		{"scalar", "1", 1, protowire.BytesType, "4.1"},
+		{"scalar", "2", 2, protowire.BytesType, "type"},
+	}, v.events)
+	v = &recordVisitor{}
+	require.NoError(t, Walk(bs, v))
+	require.Equal(t, []walkEvent{{"scalar", "70", 70, protowire.StartGroupType, "\xba\x04\x0570.71"}}, v.under(70))
+}
+
+func TestWalkError(t *testing.T) {
+	pb := malformedNested()
+	v := &recordVisitor{}
+	require.NoError(t, Walk(pb, v), "the malformed message is not descended into")
+
+	v = &recordVisitor{messages: map[protowire.Number]bool{4: true}}
+	err := Walk(pb, v)
+	require.ErrorIs(t, err, ErrInvalidLength)
+	pe := requireParseError(t, err)
+	require.Equal(t, 4, pe.Offset)
+	require.Equal(t, []protowire.Number{4}, pe.Path)
+	require.Equal(t, []walkEvent{
+		{"scalar", "1", 1, protowire.VarintType, "\x01"},
+		{"enter", "4", 4, protowire.BytesType, ""},
+	}, v.events)
+
+	err = Options{Limits: Limits{MaxDepth: 1}}.Walk(nestedMessage(3), &recordVisitor{
+		messages: map[protowire.Number]bool{1: true},
+	})
+	require.ErrorIs(t, err, ErrLimitExceeded)
+	require.Equal(t, []protowire.Number{1, 1}, requireParseError(t, err).Path)
+}
+
+// deepGroups builds the groups of field 1 nested at the levels, which take 2 bytes per level.
+func deepGroups(levels int) []byte {
+	pb := make([]byte, 0, 2*levels)
+	for i := 0; i < levels; i++ {
+		pb = protowire.AppendTag(pb, 1, protowire.StartGroupType)
+	}
+	for i := 0; i < levels; i++ {
+		pb = protowire.AppendTag(pb, 1, protowire.EndGroupType)
+	}
+	return pb
+}
+
+// deepGroupsTimeout the time to walk through deepGroups(maxGroupDepth), which takes seconds if
+// the nested groups are scanned again at each level.
+const deepGroupsTimeout = time.Second
+
+// countVisitor counts the messages entered.
+type countVisitor struct {
+	messages int
+}
+
+func (v *countVisitor) EnterMessage([]protowire.Number, Result) bool {
+	v.messages++
+	return true
+}
+
+func (v *countVisitor) LeaveMessage([]protowire.Number, Result) {}
+
+func (v *countVisitor) Scalar([]protowire.Number, Result) {}
+
+func TestWalkDeepGroups(t *testing.T) {
+	// the lengths of the nested groups are reused at each level
+	v := &recordVisitor{messages: map[protowire.Number]bool{1: true, 2: true}}
+	require.NoError(t, Walk(protoscope.MustParse(`1: !{2: !{3: 3} 2: !{4: 4}} 1: !{}`), v))
+	require.Equal(t, []walkEvent{
+		{"enter", "1", 1, protowire.StartGroupType, ""},
+		{"enter", "1.2", 2, protowire.StartGroupType, ""},
+		{"scalar", "1.2.3", 3, protowire.VarintType, "\x03"},
+		{"leave", "1.2", 2, protowire.StartGroupType, ""},
+		{"enter", "1.2", 2, protowire.StartGroupType, ""},
+		{"scalar", "1.2.4", 4, protowire.VarintType, "\x04"},
+		{"leave", "1.2", 2, protowire.StartGroupType, ""},
+		{"leave", "1", 1, protowire.StartGroupType, ""},
+		{"enter", "1", 1, protowire.StartGroupType, ""},
+		{"leave", "1", 1, protowire.StartGroupType, ""},
+	}, v.events)
+
+	pb := deepGroups(maxGroupDepth)
+	start := time.Now()
+	counter := &countVisitor{}
+	require.NoError(t, Walk(pb, counter))
+	require.Equal(t, maxGroupDepth, counter.messages)
+	counter = &countVisitor{}
+	require.NoError(t, Options{Limits: Limits{MaxFields: 1 << 20}}.Walk(pb, counter))
+	require.Equal(t, maxGroupDepth, counter.messages)
+	require.Less(t, time.Since(start), deepGroupsTimeout)
+
+	err := Options{Limits: Limits{MaxGroupDepth: 3}}.Walk(deepGroups(4), counter)
+	require.ErrorIs(t, err, ErrLimitExceeded)
+}